@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/malekim/migrater/pkg/migrater"
+	"github.com/malekim/migrater/pkg/migratercli"
+)
+
+// databaseURLEnv is used when --uri flag is not set
+const databaseURLEnv = "MIGRATER_DATABASE_URL"
+
+var (
+	migrateURI    string
+	migratePlugin string
+	migrateDir    string
+	migrateTable  string
+)
+
+// connectDatabase adds migrations loaded from the plugin
+// or the directory and connects to the database given by the uri
+func connectDatabase(ctx context.Context, mig migrater.Migrater) (func(), error) {
+	uri := migrateURI
+	if uri == "" {
+		uri = os.Getenv(databaseURLEnv)
+	}
+	if uri == "" {
+		return nil, fmt.Errorf("migrate requires --uri flag or %s env variable", databaseURLEnv)
+	}
+	if migratePlugin != "" {
+		migrations, err := loadPlugin(migratePlugin)
+		if err != nil {
+			return nil, err
+		}
+		for _, migration := range migrations {
+			mig.AddMigration(migration)
+		}
+	}
+	if migrateDir != "" {
+		migrations, err := migrater.LoadMigrations(migrateDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, migration := range migrations {
+			mig.AddMigration(migration)
+		}
+	}
+	// empty table keeps the driver default
+	mig.SetTable(migrateTable)
+	return connect(ctx, mig, uri)
+}
+
+var migrateCmd = migratercli.NewCommand(newMigrater(), connectDatabase)
+
+// newMigrater returns migrater logging
+// migrations to stderr
+func newMigrater() migrater.Migrater {
+	mig := migrater.NewMigrater()
+	mig.SetLogger(migrater.NewStdLogger(log.New(os.Stderr, "", log.LstdFlags)))
+	return mig
+}
+
+func init() {
+	migrateCmd.Long = `Run migrations against database given by --uri flag
+or MIGRATER_DATABASE_URL env variable. Driver is chosen
+by the uri scheme: mongodb, postgres, sqlite or mysql.
+
+Declarative migrations are loaded from directory given by --dir
+flag. Mongo migrations are json or yaml files with runCommand
+documents named like 1592085513_add_user_index.json. SQL
+migrations are 1592085513_add_users.up.sql and .down.sql files.
+
+Go migrations are loaded from a go plugin given by --plugin flag.
+The plugin has to export Migrations func() []migrater.Migration
+and has to be built with the same migrater version:
+
+  go build -tags purego -buildmode=plugin -o migrations.so ./plugin
+
+Build migrater binary with -tags purego as well, the mongo driver
+cannot be linked dynamically otherwise.`
+	migrateCmd.PersistentFlags().StringVar(&migrateURI, "uri", "", "database uri, defaults to "+databaseURLEnv)
+	migrateCmd.PersistentFlags().StringVar(&migratePlugin, "plugin", "", "go plugin exporting Migrations")
+	migrateCmd.PersistentFlags().StringVar(&migrateDir, "dir", "", "directory with json, yaml or sql migrations")
+	migrateCmd.PersistentFlags().StringVar(&migrateTable, "table", "", "table or collection keeping track of migrations")
+	rootCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/malekim/migrater/pkg/migrater"
+)
+
+func TestConnectDatabaseRequiresURI(t *testing.T) {
+	migrateURI = ""
+	os.Unsetenv(databaseURLEnv)
+	if _, err := connectDatabase(context.Background(), migrater.NewMigrater()); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestConnectDatabasePluginError(t *testing.T) {
+	migrateURI = "sqlite://" + filepath.Join(tempDir(t), "app.db")
+	migratePlugin = filepath.Join(tempDir(t), "missing.so")
+	defer func() {
+		migrateURI = ""
+		migratePlugin = ""
+	}()
+	if _, err := connectDatabase(context.Background(), migrater.NewMigrater()); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestMigrateStatusFromEnv(t *testing.T) {
+	migrateURI = ""
+	migratePlugin = ""
+	os.Setenv(databaseURLEnv, "sqlite://"+filepath.Join(tempDir(t), "app.db"))
+	defer os.Unsetenv(databaseURLEnv)
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"migrate", "status"})
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	}()
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.HasPrefix(buf.String(), "TIMESTAMP") {
+		t.Fatal("Expected table header, Got", buf.String())
+	}
+}
+
+func TestMigrateStatusWithDir(t *testing.T) {
+	dir := tempDir(t)
+	err := ioutil.WriteFile(filepath.Join(dir, "1592085513_add_index.json"), []byte(`{"up": [], "down": []}`), 0666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	migrateURI = "sqlite://" + filepath.Join(dir, "app.db")
+	migrateDir = dir
+	defer func() {
+		migrateURI = ""
+		migrateDir = ""
+	}()
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"migrate", "status"})
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	}()
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), "add index") || !strings.Contains(buf.String(), "pending") {
+		t.Fatal("Migration from dir should be pending, Got", buf.String())
+	}
+}
+
+func TestMigrateStatusWithTable(t *testing.T) {
+	path := filepath.Join(tempDir(t), "app.db")
+	migrateURI = "sqlite://" + path
+	migrateTable = "applied_migrations"
+	defer func() {
+		migrateURI = ""
+		migrateTable = ""
+	}()
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"migrate", "status"})
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	}()
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer db.Close()
+	var name string
+	if err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table'").Scan(&name); err != nil {
+		t.Fatal(err.Error())
+	}
+	if name != "applied_migrations" {
+		t.Fatal("Expected", "applied_migrations", "Got", name)
+	}
+}
@@ -1,35 +1,14 @@
 package cmd
 
 import (
-	"fmt"
-
-	"github.com/malekim/migrater/pkg/migrater"
-
-	"github.com/spf13/cobra"
+	"github.com/malekim/migrater/pkg/migratercli"
 )
 
-func migrationRoot(cmd *cobra.Command, args []string) error {
-	cmd.Help()
-	return fmt.Errorf("%s requires a subcommand", cmd.Name())
-}
-
-var migrationCmd = &cobra.Command{
-	Use:   "migration:generate",
-	Short: "Add migration file",
-	RunE:  migrationRoot,
-}
-
-func addMongoMigrationFile(cmd *cobra.Command, args []string) error {
-	return migrater.AddMongoMigrationFile()
-}
-
-var mongoCmd = &cobra.Command{
-	Use:   "mongo",
-	Short: "Add mongo migration file",
-	RunE:  addMongoMigrationFile,
-}
+// migrationCmd is generate command of migratercli
+// kept under its original name
+var migrationCmd = migratercli.NewGenerateCommand()
 
 func init() {
+	migrationCmd.Use = "migration:generate"
 	rootCmd.AddCommand(migrationCmd)
-	migrationCmd.AddCommand(mongoCmd)
 }
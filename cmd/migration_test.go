@@ -1,43 +1,85 @@
 package cmd
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
-	"github.com/spf13/cobra"
+	"github.com/malekim/migrater/pkg/migrater"
 )
 
+// generate runs migration:generate with args
+// and resets its flags afterwards
+func generate(args ...string) error {
+	rootCmd.SetArgs(append([]string{"migration:generate"}, args...))
+	rootCmd.SetOut(ioutil.Discard)
+	defer func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		flags := migrationCmd.PersistentFlags()
+		flags.Set("config", migrater.DefaultConfigFile)
+		for _, name := range []string{"dir", "package", "name"} {
+			flags.Set(name, "")
+		}
+	}()
+	return rootCmd.Execute()
+}
+
 func TestMigrationRoot(t *testing.T) {
-	cmd := &cobra.Command{
-		Use:   "test",
-		Short: "Test command",
-	}
-	args := []string{}
-	err := migrationRoot(cmd, args)
-	if err == nil {
+	if err := generate(); err == nil {
 		t.Error("Expected root to return an error")
 	}
 }
 
-func TestAddMongoMigrationFile(t *testing.T) {
-	cmd := &cobra.Command{
-		Use:   "test",
-		Short: "Test command",
+func TestAddMigrationFile(t *testing.T) {
+	for _, driver := range []string{"mongo", "postgres", "sqlite", "mysql"} {
+		if err := generate(driver); err != nil {
+			t.Errorf("Error during call %s command", driver)
+		}
+		dir := filepath.Join("app")
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			t.Errorf("Dir %s should exist", dir)
+		}
+		// remove testing dir
+		if err := os.RemoveAll(dir); err != nil {
+			t.Errorf("Unsuccessful clear %s", dir)
+		}
+	}
+}
+
+func TestWriteRegistry(t *testing.T) {
+	if err := generate("registry"); err == nil {
+		t.Error("Expected registry to return an error without migrations")
+	}
+	if err := generate("mongo"); err != nil {
+		t.Fatal(err.Error())
 	}
-	args := []string{}
-	err := addMongoMigrationFile(cmd, args)
+	defer os.RemoveAll("app")
+	if err := generate("registry"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(filepath.Join("app", "migrations", "registry.go")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestGenerateWithConfig(t *testing.T) {
+	dir := tempDir(t)
+	config := filepath.Join(dir, ".migrater.yaml")
+	err := ioutil.WriteFile(config, []byte("dir: "+filepath.Join(dir, "db")+"\npackage: db\n"), 0666)
 	if err != nil {
-		t.Error("Error during call addMongoMigrationFile command")
+		t.Fatal(err.Error())
 	}
-	// clean after test
-	dir := filepath.Join("app")
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		t.Errorf("Dir %s should exist", dir)
+
+	if err := generate("postgres", "--config", config, "--name", "add users"); err != nil {
+		t.Fatal(err.Error())
 	}
-	// remove testing dir
-	err = os.RemoveAll(dir)
+	files, err := filepath.Glob(filepath.Join(dir, "db", "*_add_users.go"))
 	if err != nil {
-		t.Errorf("Unsuccessful clear %s", dir)
+		t.Fatal(err.Error())
+	}
+	if len(files) != 1 {
+		t.Fatal("Expected", 1, "Got", files)
 	}
 }
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// migrationStatus runs migrate status with passed flags
+func migrationStatus(cmd *cobra.Command, args []string) error {
+	status, _, err := migrateCmd.Find([]string{"status"})
+	if err != nil {
+		return err
+	}
+	if err := status.ParseFlags(args); err != nil {
+		return err
+	}
+	return status.RunE(status, status.Flags().Args())
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "migration:status",
+	Short: "Show applied and pending migrations",
+	Long: `Show applied and pending migrations.
+
+It is an alias of migrate status and accepts the same flags,
+like --uri, --dir, --plugin, --table and --format.`,
+	DisableFlagParsing: true,
+	RunE:               migrationStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationStatus(t *testing.T) {
+	dir := tempDir(t)
+	err := ioutil.WriteFile(filepath.Join(dir, "1592085513_add_index.json"), []byte(`{"up": [], "down": []}`), 0666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		migrateURI = ""
+		migrateDir = ""
+	}()
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	rootCmd.SetArgs([]string{"migration:status", "--uri", "sqlite://" + filepath.Join(dir, "app.db"), "--dir", dir})
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	}()
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), "add index") || !strings.Contains(buf.String(), "pending") {
+		t.Fatal("Unexpected status", buf.String())
+	}
+}
+
+func TestMigrationStatusInvalidFlag(t *testing.T) {
+	if err := migrationStatus(statusCmd, []string{"--database", "app"}); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
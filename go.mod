@@ -1,9 +1,14 @@
 module github.com/malekim/migrater
 
-go 1.14
+go 1.16
 
 require (
 	bou.ke/monkey v1.0.2
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/lib/pq v1.7.0
+	github.com/mattn/go-sqlite3 v1.14.6
+	github.com/prometheus/client_golang v1.7.0
 	github.com/spf13/cobra v1.0.0
 	go.mongodb.org/mongo-driver v1.3.4
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,30 @@
+// Package migratertest keeps migrations shared by tests
+// of packages built on top of migrater. Tests of migrater
+// itself cannot import it
+package migratertest
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/malekim/migrater/pkg/migrater"
+)
+
+// SQLMigration creates table named by timestamp,
+// like t1, and drops it when reverted
+func SQLMigration(timestamp uint64) migrater.SQLMigration {
+	st := strconv.FormatUint(timestamp, 10)
+	return migrater.SQLMigration{
+		Timestamp:   timestamp,
+		Description: "Migration " + st,
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "CREATE TABLE t"+st+" (id INTEGER)")
+			return err
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DROP TABLE t"+st)
+			return err
+		},
+	}
+}
@@ -0,0 +1,34 @@
+// Package sqlitetest opens sqlite databases
+// for tests of migrater packages
+package sqlitetest
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	// sqlite driver for Open
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open opens database in a temporary file,
+// which is removed after the test. In-memory database
+// is not used, because it is lost together with
+// connection closed by cancelled context
+func Open(t *testing.T) *sql.DB {
+	dir, err := ioutil.TempDir("", "migrater")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal("Unable to open SQLite")
+	}
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+	return db
+}
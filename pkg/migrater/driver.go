@@ -0,0 +1,91 @@
+package migrater
+
+import (
+	"context"
+	"time"
+)
+
+// Direction tells if migration is applied or reverted
+type Direction int
+
+const (
+	Up Direction = iota
+	Down
+)
+
+func (d Direction) String() string {
+	if d == Down {
+		return "down"
+	}
+	return "up"
+}
+
+// Migration is implemented by every migration type
+// which can be executed by a Driver
+type Migration interface {
+	GetTimestamp() uint64
+	GetDescription() string
+}
+
+// Checksummer is implemented by migrations which
+// can tell if their code changed after they were applied
+type Checksummer interface {
+	GetChecksum() string
+}
+
+// MigrationRecord is information about applied
+// migration kept by a Driver
+//
+// Batch is the same for all migrations applied
+// by single run. Dirty is set by drivers which cannot
+// execute migration atomically, when migration failed midway
+type MigrationRecord struct {
+	Timestamp   uint64
+	Description string
+	Migrated    time.Time
+	Batch       int
+	Checksum    string
+	Dirty       bool
+}
+
+// Driver is a database specific part of migrater.
+// It executes migrations and keeps track
+// of the applied ones
+//
+// Every call should respect passed context
+type Driver interface {
+	// Lock prevents other processes from running
+	// migrations until Unlock is called. It waits
+	// for the lock held by other process at most wait
+	Lock(ctx context.Context, wait time.Duration) error
+	Unlock(ctx context.Context) error
+	// Applied returns records of all applied migrations
+	Applied(ctx context.Context) ([]MigrationRecord, error)
+	// Record saves information about applied migration
+	Record(ctx context.Context, rec MigrationRecord) error
+	// Remove deletes information about migration
+	Remove(ctx context.Context, timestamp uint64) error
+	// Execute calls Up or Down of the migration
+	// and passes ctx to the migration code
+	Execute(ctx context.Context, mgtn Migration, direction Direction) error
+}
+
+// TransactionalDriver is implemented by drivers which
+// can save information about migration in the same
+// transaction in which the migration is executed
+type TransactionalDriver interface {
+	Driver
+	// ExecuteTx executes migration and records it,
+	// or removes its record when direction is Down
+	ExecuteTx(ctx context.Context, mgtn Migration, direction Direction, rec MigrationRecord) error
+}
+
+// ResolvingDriver is implemented by drivers which mark
+// migrations failed midway as dirty
+type ResolvingDriver interface {
+	Driver
+	// Resolve clears dirty state of migration. When applied
+	// is true migration is kept as applied, otherwise
+	// its record is removed
+	Resolve(ctx context.Context, timestamp uint64, applied bool) error
+}
@@ -0,0 +1,430 @@
+package migrater
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memoryDriver keeps applied migrations in memory
+// to test migrater without any database
+type memoryDriver struct {
+	records []MigrationRecord
+	calls   []string
+	locked  bool
+}
+
+func (d *memoryDriver) Lock(ctx context.Context, wait time.Duration) error {
+	if d.locked {
+		return errors.New("Already locked")
+	}
+	d.locked = true
+	return nil
+}
+
+func (d *memoryDriver) Unlock(ctx context.Context) error {
+	d.locked = false
+	return nil
+}
+
+func (d *memoryDriver) Applied(ctx context.Context) ([]MigrationRecord, error) {
+	return d.records, nil
+}
+
+func (d *memoryDriver) Record(ctx context.Context, rec MigrationRecord) error {
+	d.records = append(d.records, rec)
+	return nil
+}
+
+func (d *memoryDriver) Remove(ctx context.Context, timestamp uint64) error {
+	for i, rec := range d.records {
+		if rec.Timestamp == timestamp {
+			d.records = append(d.records[:i], d.records[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (d *memoryDriver) Execute(ctx context.Context, mgtn Migration, direction Direction) error {
+	d.calls = append(d.calls, direction.String()+mgtn.GetDescription())
+	migration := mgtn.(MongoMigration)
+	if direction == Down {
+		return migration.Down(nil, nil)
+	}
+	return migration.Up(nil, nil)
+}
+
+func memoryMigration(timestamp uint64, description string) MongoMigration {
+	return MongoMigration{
+		Timestamp:   timestamp,
+		Description: description,
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
+			return nil
+		},
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
+			return nil
+		},
+	}
+}
+
+func TestDirectionString(t *testing.T) {
+	if Up.String() != "up" {
+		t.Fatal("Expected", "up", "Got", Up.String())
+	}
+	if Down.String() != "down" {
+		t.Fatal("Expected", "down", "Got", Down.String())
+	}
+}
+
+func TestRunWithDriver(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(1, "1"))
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(d.records) != 2 {
+		t.Fatal("Expected", 2, "Got", len(d.records))
+	}
+	if d.locked {
+		t.Fatal("Driver should be unlocked after Run")
+	}
+	// second run should not execute anything
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"up1", "up2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+}
+
+func TestRollbackWithDriver(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(3, "3"))
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	d.calls = nil
+	if err := m.Rollback("1", "3"); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"down3", "down1"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+	if len(d.records) != 1 || d.records[0].Timestamp != 2 {
+		t.Fatal("Only migration 2 should stay applied, Got", d.records)
+	}
+}
+
+func TestRunWithDriverLockError(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{locked: true}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if len(d.calls) > 0 {
+		t.Fatal("Nothing should be executed without lock")
+	}
+}
+
+func TestRunWithDirtyMigration(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{
+		records: []MigrationRecord{
+			{Timestamp: 1, Description: "1", Dirty: true},
+		},
+	}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+
+	if err := m.Run(); !errors.Is(err, ErrDirty) {
+		t.Fatal("Expected", ErrDirty, "Got", err)
+	}
+	if err := m.Rollback(); !errors.Is(err, ErrDirty) {
+		t.Fatal("Expected", ErrDirty, "Got", err)
+	}
+	if len(d.calls) > 0 {
+		t.Fatal("Nothing should be executed while migration is dirty, Got", d.calls)
+	}
+}
+
+func TestSetLockTimeout(t *testing.T) {
+	m := NewMigrater()
+	if m.lockTimeout != DefaultLockTimeout {
+		t.Fatal("Expected", DefaultLockTimeout, "Got", m.lockTimeout)
+	}
+	m.SetLockTimeout(time.Second)
+	if m.lockTimeout != time.Second {
+		t.Fatal("Expected", time.Second, "Got", m.lockTimeout)
+	}
+}
+
+func TestMigrateTo(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(3, "3"))
+
+	if err := m.MigrateTo("2"); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"up1", "up2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	d.calls = nil
+	if err := m.MigrateTo("1"); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected = []string{"down3", "down2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+	if len(d.records) != 1 || d.records[0].Timestamp != 1 {
+		t.Fatal("Only migration 1 should stay applied, Got", d.records)
+	}
+	if err := m.MigrateTo("4"); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestSteps(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(3, "3"))
+
+	if err := m.Steps(2); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.Steps(5); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.Steps(-2); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"up1", "up2", "up3", "down3", "down2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+	if len(d.records) != 1 || d.records[0].Timestamp != 1 {
+		t.Fatal("Only migration 1 should stay applied, Got", d.records)
+	}
+}
+
+func TestRollbackLastBatch(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(3, "3"))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, rec := range d.records {
+		expected := 2
+		if rec.Timestamp == 1 {
+			expected = 1
+		}
+		if rec.Batch != expected {
+			t.Fatal("Expected", expected, "Got", rec.Batch, "for migration", rec.Timestamp)
+		}
+	}
+
+	plan, err := m.PlanRollbackLastBatch()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if plan.String() != "down 3 (3)\ndown 2 (2)\n" {
+		t.Fatal("Unexpected plan", plan.String())
+	}
+	d.calls = nil
+	if err := m.RollbackLastBatch(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.RollbackLastBatch(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"down3", "down2", "down1"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+	if len(d.records) != 0 {
+		t.Fatal("Expected", 0, "Got", len(d.records))
+	}
+}
+
+func TestRollbackLastBatchWithoutBatch(t *testing.T) {
+	m := NewMigrater()
+	// records saved before batches were introduced
+	d := &memoryDriver{
+		records: []MigrationRecord{
+			{Timestamp: 1, Description: "1"},
+			{Timestamp: 2, Description: "2"},
+		},
+	}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+
+	if err := m.RollbackLastBatch(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(d.calls) > 0 || len(d.records) != 2 {
+		t.Fatal("Migrations without batch should not be reverted, Got", d.calls)
+	}
+
+	m.AddMigration(memoryMigration(3, "3"))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	d.calls = nil
+	if err := m.RollbackLastBatch(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"down3"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+}
+
+func TestRedo(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	d.calls = nil
+	if err := m.Redo(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"down2", "up2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+	if len(d.records) != 2 {
+		t.Fatal("Expected", 2, "Got", len(d.records))
+	}
+}
+
+func TestRedoKeepsBatch(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(3, "3"))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.Redo(); err != nil {
+		t.Fatal(err.Error())
+	}
+	d.calls = nil
+	if err := m.RollbackLastBatch(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"down3", "down2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+}
+
+// resolvingDriver is memoryDriver with dirty migrations
+type resolvingDriver struct {
+	memoryDriver
+}
+
+func (d *resolvingDriver) Resolve(ctx context.Context, timestamp uint64, applied bool) error {
+	for i, rec := range d.records {
+		if rec.Timestamp != timestamp {
+			continue
+		}
+		if !applied {
+			return d.Remove(ctx, timestamp)
+		}
+		d.records[i].Dirty = false
+	}
+	return nil
+}
+
+func TestResolve(t *testing.T) {
+	m := NewMigrater()
+	d := &resolvingDriver{}
+	d.records = []MigrationRecord{{Timestamp: 1, Batch: 1}, {Timestamp: 2, Batch: 1, Dirty: true}}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+
+	if err := m.Run(); !errors.Is(err, ErrDirty) {
+		t.Fatal("Expected", ErrDirty, "Got", err)
+	}
+	if err := m.Resolve("1", true); err == nil {
+		t.Fatal("Migration which is not dirty should not be resolved")
+	}
+	if err := m.Resolve("3", true); !errors.Is(err, ErrMigrationNotFound) {
+		t.Fatal("Expected", ErrMigrationNotFound, "Got", err)
+	}
+	if err := m.Resolve("2", false); err != nil {
+		t.Fatal(err.Error())
+	}
+	if d.locked {
+		t.Fatal("Lock should be released")
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"up2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	m := NewMigrater()
+	m.SetDriver(&memoryDriver{})
+	if err := m.Resolve("1", true); err == nil {
+		t.Fatal("Driver without dirty migrations should return an error")
+	}
+	m.SetDriver(&resolvingDriver{})
+	if err := m.Resolve("abc", true); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
@@ -0,0 +1,56 @@
+package migrater
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMigrationNotFound is returned when passed
+	// timestamp does not match any added migration
+	ErrMigrationNotFound = errors.New("Migration does not exist or has not been added to migrations")
+
+	// ErrLocked is returned when the lock held
+	// by other process is not released in time
+	ErrLocked = errors.New("Unable to acquire migrations lock")
+
+	// ErrDirty is returned when migration failed midway
+	// and has to be resolved manually before next run
+	ErrDirty = errors.New("Migration is dirty")
+
+	// ErrTrackingClient is returned when mongo transactions are
+	// enabled and tracking database belongs to a different client
+	ErrTrackingClient = errors.New("Mongo transactions require tracking database of the same client")
+)
+
+// MigrationError is returned when migration
+// or its bookkeeping fails. Err is the cause
+type MigrationError struct {
+	Timestamp   uint64
+	Description string
+	Direction   Direction
+	Err         error
+}
+
+func (e *MigrationError) Error() string {
+	action := "Migration"
+	if e.Direction == Down {
+		action = "Rollback migration"
+	}
+	return fmt.Sprintf("%s %d (%s) failed: %s", action, e.Timestamp, e.Description, e.Err.Error())
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// migrationNotFoundError wraps ErrMigrationNotFound
+// with passed timestamp
+func migrationNotFoundError(timestamp string) error {
+	return fmt.Errorf("%w: `%s`", ErrMigrationNotFound, timestamp)
+}
+
+// dirtyError wraps ErrDirty with dirty migration
+func dirtyError(rec MigrationRecord) error {
+	return fmt.Errorf("%w: %d (%s) failed midway and has to be resolved manually", ErrDirty, rec.Timestamp, rec.Description)
+}
@@ -0,0 +1,65 @@
+package migrater
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestMigrationError(t *testing.T) {
+	cause := errors.New("Testing purpose error")
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	failing := memoryMigration(2, "2")
+	failing.Up = func(sctx mongo.SessionContext, db *mongo.Database) error {
+		return cause
+	}
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(failing)
+
+	err := m.Run()
+	var merr *MigrationError
+	if !errors.As(err, &merr) {
+		t.Fatal("Expected *MigrationError, Got", err)
+	}
+	if merr.Timestamp != 2 || merr.Direction != Up || merr.Description != "2" {
+		t.Fatal("Unexpected error", merr)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("Error should wrap", cause)
+	}
+	expected := "Migration 2 (2) failed: Testing purpose error"
+	if err.Error() != expected {
+		t.Fatal("Expected", expected, "Got", err.Error())
+	}
+
+	failing.Down = failing.Up
+	d.records = append(d.records, MigrationRecord{Timestamp: 2, Description: "2"})
+	m.AddMigration(failing)
+	err = m.Rollback()
+	if !errors.As(err, &merr) || merr.Direction != Down || merr.Timestamp != 2 {
+		t.Fatal("Expected rollback *MigrationError, Got", err)
+	}
+	expected = "Rollback migration 2 (2) failed: Testing purpose error"
+	if err.Error() != expected {
+		t.Fatal("Expected", expected, "Got", err.Error())
+	}
+}
+
+func TestErrMigrationNotFound(t *testing.T) {
+	m := NewMigrater()
+	m.SetDriver(&memoryDriver{})
+	m.AddMigration(memoryMigration(1, "1"))
+
+	if err := m.Rollback("2"); !errors.Is(err, ErrMigrationNotFound) {
+		t.Fatal("Expected", ErrMigrationNotFound, "Got", err)
+	}
+	if err := m.MigrateTo("2"); !errors.Is(err, ErrMigrationNotFound) {
+		t.Fatal("Expected", ErrMigrationNotFound, "Got", err)
+	}
+	if _, err := m.PlanRollback("2"); !errors.Is(err, ErrMigrationNotFound) {
+		t.Fatal("Expected", ErrMigrationNotFound, "Got", err)
+	}
+}
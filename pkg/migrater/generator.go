@@ -0,0 +1,284 @@
+package migrater
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/malekim/migrater/internal/utils"
+	"gopkg.in/yaml.v2"
+)
+
+// registryFile is a name of generated file
+// listing all migrations of the package
+const registryFile = "registry.go"
+
+var registryStub string = `// Code generated by migrater. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"github.com/malekim/migrater/pkg/migrater"
+)
+
+// All returns all migrations of the package
+func All() []migrater.{{ .Type }} {
+	return []migrater.{{ .Type }}{
+{{- range .Names }}
+		{{ . }},
+{{- end }}
+	}
+}
+`
+
+// DefaultConfigFile is a project config
+// read by migrater CLI
+const DefaultConfigFile = ".migrater.yaml"
+
+// GeneratorConfig tells where and how migration files
+// are generated. Empty fields fall back to app/migrations
+// directory and migrations package. Name is a description
+// of the migration, which is also added to the file name
+type GeneratorConfig struct {
+	Dir     string `yaml:"dir"`
+	Package string `yaml:"package"`
+	Name    string `yaml:"-"`
+}
+
+// LoadGeneratorConfig reads generator config from yaml file.
+// Missing file gives empty config
+func LoadGeneratorConfig(path string) (GeneratorConfig, error) {
+	cfg := GeneratorConfig{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("Invalid config %s: %s", path, err.Error())
+	}
+	return cfg, nil
+}
+
+// GenerateMigrationFile creates migration file for driver,
+// which is one of mongo, postgres, sqlite or mysql
+func GenerateMigrationFile(driver string, cfg GeneratorConfig) error {
+	switch driver {
+	case "mongo":
+		return addMigrationFile(mongoStub, cfg)
+	case "postgres", "sqlite", "mysql":
+		return addMigrationFile(sqlStub, cfg)
+	}
+	return fmt.Errorf("Unknown driver `%s`, use mongo, postgres, sqlite or mysql", driver)
+}
+
+// addMigrationFile creates file named by current timestamp
+// and migration name from passed stub and updates the registry
+func addMigrationFile(stub string, cfg GeneratorConfig) error {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join("app", "migrations")
+	}
+	if cfg.Package == "" {
+		cfg.Package = "migrations"
+	}
+	if !token.IsIdentifier(cfg.Package) {
+		return fmt.Errorf("Package name `%s` is not a valid identifier", cfg.Package)
+	}
+	description := cfg.Name
+	if description == "" {
+		description = "Your description"
+	}
+	_, names, _, err := declaredMigrations(cfg.Dir)
+	if err != nil {
+		return err
+	}
+	declared := map[string]bool{}
+	for _, n := range names {
+		declared[n] = true
+	}
+	timestamp := time.Now().Unix()
+	var name, path string
+	// migrations generated in the same second
+	// get the next free timestamp
+	for {
+		name = fmt.Sprintf("%d.go", timestamp)
+		if suffix := fileSuffix(cfg.Name); suffix != "" {
+			name = fmt.Sprintf("%d_%s.go", timestamp, suffix)
+		}
+		path = filepath.Join(cfg.Dir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) && !declared[fmt.Sprintf("Migration%d", timestamp)] {
+			break
+		}
+		timestamp++
+	}
+	t := template.Must(template.New("").Parse(stub))
+	if err := utils.EnsureDir(path); err != nil {
+		log.Printf("Error creating dir: %s", err.Error())
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		log.Printf("Error opening file: %s", err.Error())
+		return err
+	}
+	defer f.Close()
+
+	vars := struct {
+		Timestamp   int64
+		Package     string
+		Description string
+	}{
+		timestamp,
+		cfg.Package,
+		description,
+	}
+
+	err = t.Execute(f, vars)
+	if err != nil {
+		return err
+	}
+	log.Printf("Created %s\n", name)
+	return WriteRegistry(cfg.Dir)
+}
+
+// fileSuffix turns migration name like "Add user index"
+// into add_user_index
+func fileSuffix(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			underscore = false
+		} else {
+			underscore = true
+		}
+	}
+	return b.String()
+}
+
+// WriteRegistry writes registry.go to dir with All function
+// returning every MigrationNNN variable declared in dir.
+// It is called by generator, call it after removing
+// a migration file
+func WriteRegistry(dir string) error {
+	pkg, names, types, err := declaredMigrations(dir)
+	if err != nil {
+		return err
+	}
+	if pkg == "" {
+		return fmt.Errorf("There are no migration files in %s", dir)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return migrationNumber(names[i]) < migrationNumber(names[j])
+	})
+	typ := "Migration"
+	if len(types) == 1 {
+		for t := range types {
+			typ = t
+		}
+	}
+
+	var buf bytes.Buffer
+	t := template.Must(template.New("").Parse(registryStub))
+	err = t.Execute(&buf, struct {
+		Package string
+		Type    string
+		Names   []string
+	}{pkg, typ, names})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, registryFile), src, 0666)
+}
+
+// declaredMigrations parses go files in dir and returns
+// package name, unique MigrationNNN variables and their types
+func declaredMigrations(dir string) (string, []string, map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	fset := token.NewFileSet()
+	pkg := ""
+	types := map[string]bool{}
+	seen := map[string]bool{}
+	names := []string{}
+	for _, file := range files {
+		base := filepath.Base(file)
+		if base == registryFile || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		pkg = f.Name.Name
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				typ := migrationType(vs.Type)
+				if typ == "" {
+					continue
+				}
+				for _, n := range vs.Names {
+					if strings.HasPrefix(n.Name, "Migration") && !seen[n.Name] {
+						seen[n.Name] = true
+						names = append(names, n.Name)
+						types[typ] = true
+					}
+				}
+			}
+		}
+	}
+	return pkg, names, types, nil
+}
+
+// migrationType returns name of migrater type
+// of declared variable, like MongoMigration
+func migrationType(expr ast.Expr) string {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "migrater" {
+		return ""
+	}
+	switch sel.Sel.Name {
+	case "MongoMigration", "SQLMigration":
+		return sel.Sel.Name
+	}
+	return ""
+}
+
+// migrationNumber returns timestamp from
+// variable name like Migration1592085513
+func migrationNumber(name string) uint64 {
+	n, _ := strconv.ParseUint(strings.TrimPrefix(name, "Migration"), 10, 64)
+	return n
+}
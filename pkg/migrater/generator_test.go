@@ -0,0 +1,214 @@
+package migrater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMigrationFile(t *testing.T, dir string, name string, src string) {
+	err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0666)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func readRegistry(t *testing.T, dir string) string {
+	src, err := ioutil.ReadFile(filepath.Join(dir, registryFile))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return string(src)
+}
+
+func TestWriteRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	writeMigrationFile(t, dir, "10.go", `package migrations
+import "github.com/malekim/migrater/pkg/migrater"
+var Migration10 migrater.MongoMigration = migrater.MongoMigration{Timestamp: 10}
+var helper = 1
+`)
+	writeMigrationFile(t, dir, "9.go", `package migrations
+import "github.com/malekim/migrater/pkg/migrater"
+var Migration9 migrater.MongoMigration = migrater.MongoMigration{Timestamp: 9}
+`)
+
+	if err := WriteRegistry(dir); err != nil {
+		t.Fatal(err.Error())
+	}
+	registry := readRegistry(t, dir)
+	if !strings.Contains(registry, "func All() []migrater.MongoMigration {") {
+		t.Fatal("Unexpected registry", registry)
+	}
+	if strings.Index(registry, "Migration9,") > strings.Index(registry, "Migration10,") {
+		t.Fatal("Migrations should be sorted by timestamp", registry)
+	}
+	if strings.Contains(registry, "helper") {
+		t.Fatal("Only migrations should be registered", registry)
+	}
+
+	// registry of mixed migrations returns Migration interface
+	writeMigrationFile(t, dir, "11.go", `package migrations
+import "github.com/malekim/migrater/pkg/migrater"
+var Migration11 migrater.SQLMigration = migrater.SQLMigration{Timestamp: 11}
+`)
+	if err := WriteRegistry(dir); err != nil {
+		t.Fatal(err.Error())
+	}
+	registry = readRegistry(t, dir)
+	if !strings.Contains(registry, "func All() []migrater.Migration {") {
+		t.Fatal("Unexpected registry", registry)
+	}
+}
+
+func TestWriteRegistryDuplicate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	src := `package migrations
+import "github.com/malekim/migrater/pkg/migrater"
+var Migration10 migrater.MongoMigration = migrater.MongoMigration{Timestamp: 10}
+`
+	writeMigrationFile(t, dir, "10.go", src)
+	writeMigrationFile(t, dir, "10_copy.go", src)
+
+	if err := WriteRegistry(dir); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n := strings.Count(readRegistry(t, dir), "Migration10,"); n != 1 {
+		t.Fatal("Expected", 1, "Got", n)
+	}
+}
+
+func TestWriteRegistryEmptyDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteRegistry(dir); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestAddMigrationFileWritesRegistry(t *testing.T) {
+	if err := AddSQLiteMigrationFile(); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll("app")
+	registry := readRegistry(t, filepath.Join("app", "migrations"))
+	if !strings.Contains(registry, "func All() []migrater.SQLMigration {") {
+		t.Fatal("Unexpected registry", registry)
+	}
+}
+
+func TestGenerateMigrationFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	cfg := GeneratorConfig{Dir: filepath.Join(dir, "db"), Package: "db", Name: "Add user index"}
+	if err := GenerateMigrationFile("mongo", cfg); err != nil {
+		t.Fatal(err.Error())
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "db", "*_add_user_index.go"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(files) != 1 {
+		t.Fatal("Expected", 1, "Got", files)
+	}
+	src, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(src), "package db") || !strings.Contains(string(src), `Description: "Add user index"`) {
+		t.Fatal("Unexpected migration file", string(src))
+	}
+	if !strings.Contains(readRegistry(t, filepath.Join(dir, "db")), "package db") {
+		t.Fatal("Registry should be in package db")
+	}
+}
+
+func TestGenerateMigrationFileSameSecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	cfg := GeneratorConfig{Dir: dir, Name: "Add index"}
+	for i := 0; i < 3; i++ {
+		if err := GenerateMigrationFile("mongo", cfg); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	_, names, _, err := declaredMigrations(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(names) != 3 {
+		t.Fatal("Expected", 3, "Got", names)
+	}
+}
+
+func TestGenerateMigrationFileErrors(t *testing.T) {
+	if err := GenerateMigrationFile("redis", GeneratorConfig{}); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if err := GenerateMigrationFile("mongo", GeneratorConfig{Package: "my-migrations"}); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestFileSuffix(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"Add user index":   "add_user_index",
+		"  rename: a->b  ": "rename_a_b",
+	}
+	for name, expected := range tests {
+		if suffix := fileSuffix(name); suffix != expected {
+			t.Fatal("Expected", expected, "Got", suffix)
+		}
+	}
+}
+
+func TestLoadGeneratorConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	cfg, err := LoadGeneratorConfig(filepath.Join(dir, DefaultConfigFile))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cfg != (GeneratorConfig{}) {
+		t.Fatal("Missing config should be empty, Got", cfg)
+	}
+
+	path := filepath.Join(dir, DefaultConfigFile)
+	writeMigrationFile(t, dir, DefaultConfigFile, "dir: db/migrations\npackage: db\n")
+	cfg, err = LoadGeneratorConfig(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if cfg.Dir != "db/migrations" || cfg.Package != "db" {
+		t.Fatal("Unexpected config", cfg)
+	}
+
+	writeMigrationFile(t, dir, DefaultConfigFile, "dir: [")
+	if _, err := LoadGeneratorConfig(path); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
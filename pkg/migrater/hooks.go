@@ -0,0 +1,98 @@
+package migrater
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSkipMigration can be returned by BeforeEach hook
+// to skip the migration without stopping the others
+var ErrSkipMigration = errors.New("Migration skipped by hook")
+
+// MigrationEvent describes migration passed to hooks.
+// Duration and Err are set after the migration
+type MigrationEvent struct {
+	Migration Migration
+	Direction Direction
+	Duration  time.Duration
+	Err       error
+}
+
+// Hooks are called around migrations executed by Run,
+// Rollback and other commands. Any of them may be nil
+//
+// BeforeAll and AfterAll are called once per plan which
+// has any migrations. Redo and MigrateTo execute plans
+// for both directions, so they call them twice
+type Hooks struct {
+	// BeforeAll is called before the first migration,
+	// returned error stops the plan
+	BeforeAll func(ctx context.Context, plan *Plan) error
+	// BeforeEach is called before every migration.
+	// Returned error vetoes the migration and stops the plan,
+	// ErrSkipMigration skips only the migration
+	BeforeEach func(ctx context.Context, event MigrationEvent) error
+	// AfterEach is called after successful migration
+	AfterEach func(ctx context.Context, event MigrationEvent)
+	// AfterAll is called when all migrations of the plan succeeded
+	AfterAll func(ctx context.Context, plan *Plan, duration time.Duration)
+	// OnError is called after failed migration
+	OnError func(ctx context.Context, event MigrationEvent)
+}
+
+// AddHooks registers hooks. Hooks are called
+// in order of registration
+func (m *migrater) AddHooks(hooks Hooks) {
+	m.hooks = append(m.hooks, hooks)
+}
+
+func (m *migrater) beforeAll(ctx context.Context, plan *Plan) error {
+	for _, h := range m.hooks {
+		if h.BeforeAll == nil {
+			continue
+		}
+		if err := h.BeforeAll(ctx, plan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beforeEach returns false when the migration
+// should be skipped
+func (m *migrater) beforeEach(ctx context.Context, migration Migration, direction Direction) (bool, error) {
+	event := MigrationEvent{Migration: migration, Direction: direction}
+	for _, h := range m.hooks {
+		if h.BeforeEach == nil {
+			continue
+		}
+		err := h.BeforeEach(ctx, event)
+		if errors.Is(err, ErrSkipMigration) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (m *migrater) afterEach(ctx context.Context, event MigrationEvent) {
+	for _, h := range m.hooks {
+		if event.Err != nil && h.OnError != nil {
+			h.OnError(ctx, event)
+		}
+		if event.Err == nil && h.AfterEach != nil {
+			h.AfterEach(ctx, event)
+		}
+	}
+}
+
+func (m *migrater) afterAll(ctx context.Context, plan *Plan, duration time.Duration) {
+	for _, h := range m.hooks {
+		if h.AfterAll != nil {
+			h.AfterAll(ctx, plan, duration)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package migrater
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// recordHooks returns hooks appending
+// their calls to passed slice
+func recordHooks(calls *[]string) Hooks {
+	return Hooks{
+		BeforeAll: func(ctx context.Context, plan *Plan) error {
+			*calls = append(*calls, "beforeAll "+plan.Direction.String())
+			return nil
+		},
+		BeforeEach: func(ctx context.Context, event MigrationEvent) error {
+			*calls = append(*calls, "beforeEach "+event.Migration.GetDescription())
+			return nil
+		},
+		AfterEach: func(ctx context.Context, event MigrationEvent) {
+			*calls = append(*calls, "afterEach "+event.Migration.GetDescription()+" "+event.Direction.String())
+		},
+		AfterAll: func(ctx context.Context, plan *Plan, duration time.Duration) {
+			*calls = append(*calls, "afterAll "+plan.Direction.String())
+		},
+		OnError: func(ctx context.Context, event MigrationEvent) {
+			*calls = append(*calls, "onError "+event.Migration.GetDescription()+" "+event.Err.Error())
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	m := NewMigrater()
+	m.SetDriver(&memoryDriver{})
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+	calls := []string{}
+	m.AddHooks(recordHooks(&calls))
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{
+		"beforeAll up",
+		"beforeEach 1", "afterEach 1 up",
+		"beforeEach 2", "afterEach 2 up",
+		"afterAll up",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatal("Expected", expected, "Got", calls)
+	}
+
+	// nothing to do does not call hooks
+	calls = calls[:0]
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(calls) > 0 {
+		t.Fatal("Hooks should not be called, Got", calls)
+	}
+
+	if err := m.Rollback("2"); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected = []string{"beforeAll down", "beforeEach 2", "afterEach 2 down", "afterAll down"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatal("Expected", expected, "Got", calls)
+	}
+}
+
+func TestHooksOnError(t *testing.T) {
+	m := NewMigrater()
+	m.SetDriver(&memoryDriver{})
+	failing := memoryMigration(1, "1")
+	failing.Up = func(sctx mongo.SessionContext, db *mongo.Database) error {
+		return errors.New("Testing purpose error")
+	}
+	m.AddMigration(failing)
+	calls := []string{}
+	m.AddHooks(recordHooks(&calls))
+
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	expected := []string{"beforeAll up", "beforeEach 1", "onError 1 Testing purpose error"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatal("Expected", expected, "Got", calls)
+	}
+}
+
+func TestHooksVeto(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(3, "3"))
+	veto := errors.New("Backup failed")
+	m.AddHooks(Hooks{
+		BeforeEach: func(ctx context.Context, event MigrationEvent) error {
+			switch event.Migration.GetTimestamp() {
+			case 1:
+				return ErrSkipMigration
+			case 3:
+				return veto
+			}
+			return nil
+		},
+	})
+
+	err := m.Run()
+	var merr *MigrationError
+	if !errors.As(err, &merr) || merr.Timestamp != 3 || !errors.Is(err, veto) {
+		t.Fatal("Expected vetoed migration 3, Got", err)
+	}
+	expected := []string{"up2"}
+	if !reflect.DeepEqual(d.calls, expected) {
+		t.Fatal("Expected", expected, "Got", d.calls)
+	}
+}
+
+func TestHooksBeforeAllError(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddHooks(Hooks{
+		BeforeAll: func(ctx context.Context, plan *Plan) error {
+			return errors.New("Snapshot failed")
+		},
+	})
+
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if len(d.calls) > 0 {
+		t.Fatal("Nothing should be executed, Got", d.calls)
+	}
+}
+
+func TestHooksRedoSkipped(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	d.calls = nil
+	m.AddHooks(Hooks{
+		BeforeEach: func(ctx context.Context, event MigrationEvent) error {
+			if event.Direction == Down {
+				return ErrSkipMigration
+			}
+			return nil
+		},
+	})
+
+	if err := m.Redo(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(d.calls) > 0 {
+		t.Fatal("Skipped migration should not be applied again, Got", d.calls)
+	}
+	if len(d.records) != 2 {
+		t.Fatal("Expected", 2, "Got", len(d.records))
+	}
+}
@@ -0,0 +1,176 @@
+package migrater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LoadMigrations reads declarative migrations from dir.
+// Files are named <timestamp>_<description>.<ext>,
+// json, yaml and yml files are mongo migrations,
+// <timestamp>_<description>.up.sql and .down.sql files
+// are sql migrations. Other files are skipped
+//
+// Checksum of the migration is computed from the file
+func LoadMigrations(dir string) ([]Migration, error) {
+	return LoadMigrationsFS(os.DirFS(dir), ".")
+}
+
+// LoadMigrationsFS reads declarative migrations from dir
+// of fsys, so migrations can be embedded with embed.FS
+func LoadMigrationsFS(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	migrations := []Migration{}
+	scripts := map[uint64]*sqlFile{}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		ext := path.Ext(name)
+		if ext != ".json" && ext != ".yaml" && ext != ".yml" && ext != ".sql" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if ext == ".sql" {
+			if err := addSQLFile(scripts, name, data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		migration, err := parseMongoFile(name, data)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration)
+	}
+	for _, file := range scripts {
+		migration, err := file.migration()
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].GetTimestamp() < migrations[j].GetTimestamp()
+	})
+	return migrations, nil
+}
+
+// sqlFile collects up and down files
+// of one sql migration
+type sqlFile struct {
+	timestamp   uint64
+	description string
+	up, down    []byte
+}
+
+// addSQLFile adds .up.sql or .down.sql file
+// to the migration with the same timestamp
+func addSQLFile(scripts map[uint64]*sqlFile, name string, data []byte) error {
+	base := strings.TrimSuffix(name, ".sql")
+	direction := path.Ext(base)
+	if direction != ".up" && direction != ".down" {
+		return fmt.Errorf("Migration file name %s has to end with .up.sql or .down.sql", name)
+	}
+	timestamp, description, err := parseFileName(base)
+	if err != nil {
+		return err
+	}
+	file, ok := scripts[timestamp]
+	if !ok {
+		file = &sqlFile{timestamp: timestamp}
+		scripts[timestamp] = file
+	}
+	if direction == ".up" {
+		if file.up != nil {
+			return fmt.Errorf("Migration %d has more than one up file", timestamp)
+		}
+		file.up, file.description = data, description
+		return nil
+	}
+	if file.down != nil {
+		return fmt.Errorf("Migration %d has more than one down file", timestamp)
+	}
+	file.down = data
+	return nil
+}
+
+// migration creates sql file migration.
+// Down file is optional, up file is required
+func (file *sqlFile) migration() (SQLFileMigration, error) {
+	if file.up == nil {
+		return SQLFileMigration{}, fmt.Errorf("Migration %d has no up file", file.timestamp)
+	}
+	return SQLFileMigration{
+		Timestamp:   file.timestamp,
+		Description: file.description,
+		Checksum:    fileChecksum(append(append([]byte{}, file.up...), file.down...)),
+		Up:          parseSQLScript(string(file.up), false),
+		Down:        parseSQLScript(string(file.down), false),
+	}, nil
+}
+
+// parseMongoFile creates mongo migration from json or yaml file
+func parseMongoFile(name string, data []byte) (MongoCommandMigration, error) {
+	migration := MongoCommandMigration{}
+	timestamp, description, err := parseFileName(name)
+	if err != nil {
+		return migration, err
+	}
+	var file mongoCommandFile
+	if filepath.Ext(name) == ".json" {
+		file, err = parseMongoJSON(data)
+	} else {
+		file, err = parseMongoYAML(data)
+	}
+	if err != nil {
+		return migration, fmt.Errorf("Invalid migration file %s: %s", name, err.Error())
+	}
+	if file.Description != "" {
+		description = file.Description
+	}
+	return MongoCommandMigration{
+		Timestamp:   timestamp,
+		Description: description,
+		Checksum:    fileChecksum(data),
+		Up:          file.Up,
+		Down:        file.Down,
+	}, nil
+}
+
+// parseFileName returns timestamp and description
+// from file name like 1592085513_add_user_index.json
+func parseFileName(name string) (uint64, string, error) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	parts := strings.SplitN(base, "_", 2)
+	timestamp, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("Migration file name %s has to start with timestamp", name)
+	}
+	description := ""
+	if len(parts) == 2 {
+		description = strings.ReplaceAll(parts[1], "_", " ")
+	}
+	return timestamp, description, nil
+}
+
+// fileChecksum returns sha256 of migration file
+func fileChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
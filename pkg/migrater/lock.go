@@ -0,0 +1,52 @@
+package migrater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultLockTimeout is how long migrater waits
+// for the lock held by other process
+const DefaultLockTimeout = time.Minute
+
+// lockPollInterval is a pause between attempts
+// to acquire the lock
+var lockPollInterval = 500 * time.Millisecond
+
+// acquireLock calls try until it obtains the lock,
+// wait time passes or ctx is done
+func acquireLock(ctx context.Context, wait time.Duration, try func() (bool, error)) error {
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err := try()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return lockTimeoutError(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func lockTimeoutError(wait time.Duration) error {
+	return fmt.Errorf("%w within %s, other process is running migrations", ErrLocked, wait)
+}
+
+// lockOwner identifies current process as lock owner
+func lockOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
@@ -0,0 +1,63 @@
+package migrater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	defer func(interval time.Duration) {
+		lockPollInterval = interval
+	}(lockPollInterval)
+	lockPollInterval = time.Millisecond
+	attempts := 0
+	err := acquireLock(context.Background(), time.Second, func() (bool, error) {
+		attempts++
+		return attempts == 3, nil
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if attempts != 3 {
+		t.Fatal("Expected", 3, "Got", attempts)
+	}
+}
+
+func TestAcquireLockTimeout(t *testing.T) {
+	defer func(interval time.Duration) {
+		lockPollInterval = interval
+	}(lockPollInterval)
+	lockPollInterval = time.Millisecond
+	err := acquireLock(context.Background(), 10*time.Millisecond, func() (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, ErrLocked) {
+		t.Fatal("Expected", ErrLocked, "Got", err)
+	}
+}
+
+func TestAcquireLockError(t *testing.T) {
+	err := acquireLock(context.Background(), time.Second, func() (bool, error) {
+		return false, errors.New("Testing purpose error")
+	})
+	if err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestAcquireLockContextCancelled(t *testing.T) {
+	defer func(interval time.Duration) {
+		lockPollInterval = interval
+	}(lockPollInterval)
+	lockPollInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := acquireLock(ctx, time.Minute, func() (bool, error) {
+		return false, nil
+	})
+	if err != context.Canceled {
+		t.Fatal("Expected", context.Canceled, "Got", err)
+	}
+}
@@ -0,0 +1,80 @@
+package migrater
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Logger receives structured events about migrations.
+// args are alternating keys and values, so *slog.Logger
+// can be passed to SetLogger as it is
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// nopLogger is the default logger,
+// so the library does not write anything
+type nopLogger struct{}
+
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// stdLogger writes events with standard logger
+// as message followed by key=value pairs
+type stdLogger struct {
+	l *log.Logger
+}
+
+// NewStdLogger returns logger writing events
+// to l, or to the standard logger when l is nil
+func NewStdLogger(l *log.Logger) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return &stdLogger{l: l}
+}
+
+func (s *stdLogger) Info(msg string, args ...interface{}) {
+	s.l.Println(formatEvent(msg, args))
+}
+
+func (s *stdLogger) Error(msg string, args ...interface{}) {
+	s.l.Println(formatEvent("ERROR "+msg, args))
+}
+
+// formatEvent joins message and key=value pairs.
+// Value without key is printed with !BADKEY key like slog does
+func formatEvent(msg string, args []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(&b, " !BADKEY=%s", formatValue(args[i]))
+			break
+		}
+		fmt.Fprintf(&b, " %v=%s", args[i], formatValue(args[i+1]))
+	}
+	return b.String()
+}
+
+// formatValue quotes values which are empty
+// or contain spaces, quotes or equal signs
+func formatValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// SetLogger sets logger receiving events about applied
+// and reverted migrations. Nil disables logging
+func (m *migrater) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	m.logger = logger
+}
@@ -0,0 +1,21 @@
+//go:build go1.21
+// +build go1.21
+
+package migrater
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewMigrater()
+	m.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	m.logger.Info("Migration succeeded", "timestamp", uint64(1), "description", "add users")
+	if !strings.Contains(buf.String(), `msg="Migration succeeded" timestamp=1 description="add users"`) {
+		t.Fatal("Unexpected output", buf.String())
+	}
+}
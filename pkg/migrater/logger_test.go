@@ -0,0 +1,136 @@
+package migrater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/malekim/migrater/internal/sqlitetest"
+)
+
+// event is a message logged by memoryLogger
+type event struct {
+	level string
+	msg   string
+	args  map[string]interface{}
+}
+
+// memoryLogger keeps logged events
+type memoryLogger struct {
+	events []event
+}
+
+func (l *memoryLogger) log(level, msg string, args []interface{}) {
+	e := event{level: level, msg: msg, args: map[string]interface{}{}}
+	for i := 0; i+1 < len(args); i += 2 {
+		e.args[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.events = append(l.events, e)
+}
+
+func (l *memoryLogger) Info(msg string, args ...interface{}) {
+	l.log("info", msg, args)
+}
+
+func (l *memoryLogger) Error(msg string, args ...interface{}) {
+	l.log("error", msg, args)
+}
+
+func TestFormatEvent(t *testing.T) {
+	got := formatEvent("Migration succeeded", []interface{}{"timestamp", 1, "description", "add users", "empty", "", "odd"})
+	expected := `Migration succeeded timestamp=1 description="add users" empty="" !BADKEY=odd`
+	if got != expected {
+		t.Fatal("Expected", expected, "Got", got)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+	logger.Info("Migration succeeded", "timestamp", 1)
+	logger.Error("Migration failed", "timestamp", 2)
+	expected := "Migration succeeded timestamp=1\nERROR Migration failed timestamp=2\n"
+	if buf.String() != expected {
+		t.Fatal("Expected", expected, "Got", buf.String())
+	}
+}
+
+func TestSetLoggerNil(t *testing.T) {
+	m := NewMigrater()
+	m.SetLogger(nil)
+	if _, ok := m.logger.(nopLogger); !ok {
+		t.Fatal("Expected", "nopLogger", "Got", m.logger)
+	}
+}
+
+func TestSQLiteLogger(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+	logger := &memoryLogger{}
+	m.SetLogger(logger)
+
+	calls := []string{}
+	m.AddSQLMigration(sqliteMigration(1, &calls))
+	broken := sqliteMigration(2, &calls)
+	broken.Up = sqliteMigration(1, &calls).Up
+	m.AddSQLMigration(broken)
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if len(logger.events) != 2 {
+		t.Fatal("Expected", 2, "Got", len(logger.events))
+	}
+	ok, failed := logger.events[0], logger.events[1]
+	if ok.level != "info" || ok.args["timestamp"] != uint64(1) || ok.args["outcome"] != "success" {
+		t.Fatal("Unexpected event", ok)
+	}
+	if ok.args["description"] != "Migration 1" || ok.args["direction"] != "up" {
+		t.Fatal("Unexpected event", ok)
+	}
+	if _, has := ok.args["duration"]; !has {
+		t.Fatal("Event should have duration", ok)
+	}
+	if failed.level != "error" || failed.args["timestamp"] != uint64(2) || failed.args["outcome"] != "failure" {
+		t.Fatal("Unexpected event", failed)
+	}
+	if !strings.Contains(fmt.Sprint(failed.args["error"]), "already exists") {
+		t.Fatal("Unexpected error", failed.args["error"])
+	}
+
+	logger.events = nil
+	if err := m.Rollback("1"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(logger.events) != 1 || logger.events[0].args["direction"] != "down" {
+		t.Fatal("Unexpected events", logger.events)
+	}
+}
+
+// unlockErrorDriver fails to release the lock
+type unlockErrorDriver struct {
+	memoryDriver
+}
+
+func (d *unlockErrorDriver) Unlock(ctx context.Context) error {
+	return errors.New("Testing purpose error")
+}
+
+func TestUnlockErrorLogged(t *testing.T) {
+	m := NewMigrater()
+	m.SetDriver(&unlockErrorDriver{})
+	logger := &memoryLogger{}
+	m.SetLogger(logger)
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(logger.events) == 0 || logger.events[0].level != "error" || logger.events[0].args["error"] == nil {
+		t.Fatal("Unlock error should be logged, Got", logger.events)
+	}
+}
@@ -0,0 +1,48 @@
+package migrater
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics records migration runs. Package
+// migraterprom implements it with prometheus
+type Metrics interface {
+	// ObserveMigration is called after every
+	// applied, reverted or failed migration
+	ObserveMigration(event MigrationEvent)
+	// SetVersion is called after every command with
+	// timestamp of the latest applied migration,
+	// zero when nothing is applied
+	SetVersion(timestamp uint64)
+	// ObserveLockWait is called with time
+	// spent acquiring the lock
+	ObserveLockWait(wait time.Duration)
+}
+
+// SetMetrics sets metrics collector.
+// Nil disables metrics
+func (m *migrater) SetMetrics(metrics Metrics) {
+	m.metrics = metrics
+}
+
+// reportVersion passes timestamp of the latest
+// applied migration to metrics collector
+func (m *migrater) reportVersion() {
+	if m.metrics == nil {
+		return
+	}
+	// version has to be reported even when ctx is cancelled
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		m.logger.Error("Unable to read schema version", "error", err)
+		return
+	}
+	var version uint64
+	for _, rec := range records {
+		if rec.Timestamp > version {
+			version = rec.Timestamp
+		}
+	}
+	m.metrics.SetVersion(version)
+}
@@ -0,0 +1,57 @@
+package migrater
+
+import (
+	"testing"
+	"time"
+)
+
+// memoryMetrics keeps recorded metrics
+type memoryMetrics struct {
+	events    []MigrationEvent
+	version   uint64
+	lockWaits int
+}
+
+func (mm *memoryMetrics) ObserveMigration(event MigrationEvent) {
+	mm.events = append(mm.events, event)
+}
+
+func (mm *memoryMetrics) SetVersion(timestamp uint64) {
+	mm.version = timestamp
+}
+
+func (mm *memoryMetrics) ObserveLockWait(wait time.Duration) {
+	mm.lockWaits++
+}
+
+func TestMetrics(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	mm := &memoryMetrics{}
+	m.SetMetrics(mm)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(mm.events) != 2 || mm.version != 2 || mm.lockWaits != 1 {
+		t.Fatal("Unexpected metrics", mm)
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(mm.events) != 4 || mm.events[3].Direction != Down || mm.version != 0 {
+		t.Fatal("Unexpected metrics", mm)
+	}
+
+	// lock wait is recorded even when lock fails
+	d.locked = true
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if mm.lockWaits != 3 {
+		t.Fatal("Expected", 3, "Got", mm.lockWaits)
+	}
+}
@@ -1,124 +1,641 @@
 package migrater
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
-	"log"
+	"sort"
 	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// Migrater is implemented by migrater returned from
+// NewMigrater, so other packages can accept it
+type Migrater interface {
+	AddMigration(mgtn Migration)
+	AddMongoMigration(mgtn MongoMigration)
+	AddSQLMigration(mgtn SQLMigration)
+	SetDriver(driver Driver)
+	SetMongoDatabase(db *mongo.Database)
+	SetMongoTransactions(enabled bool)
+	SetPostgresDatabase(db *sql.DB)
+	SetSQLiteDatabase(db *sql.DB)
+	SetMySQLDatabase(db *sql.DB)
+	SetTable(name string)
+	SetMongoTrackingDatabase(db *mongo.Database)
+	SetSQLTrackingDatabase(db *sql.DB)
+	SetLockTimeout(timeout time.Duration)
+	SetMigrationTimeout(timeout time.Duration)
+	SetLogger(logger Logger)
+	AddHooks(hooks Hooks)
+	SetMetrics(metrics Metrics)
+	SetTracer(tracer Tracer)
+	Run() error
+	RunContext(ctx context.Context) error
+	Rollback(timestamps ...string) error
+	RollbackContext(ctx context.Context, timestamps ...string) error
+	RollbackLastBatch() error
+	RollbackLastBatchContext(ctx context.Context) error
+	MigrateTo(timestamp string) error
+	MigrateToContext(ctx context.Context, timestamp string) error
+	Steps(n int) error
+	StepsContext(ctx context.Context, n int) error
+	Redo() error
+	RedoContext(ctx context.Context) error
+	Resolve(timestamp string, applied bool) error
+	ResolveContext(ctx context.Context, timestamp string, applied bool) error
+	PlanRun() (*Plan, error)
+	PlanRunContext(ctx context.Context) (*Plan, error)
+	PlanRollback(timestamps ...string) (*Plan, error)
+	PlanRollbackContext(ctx context.Context, timestamps ...string) (*Plan, error)
+	PlanSteps(n int) (*Plan, error)
+	PlanStepsContext(ctx context.Context, n int) (*Plan, error)
+	PlanRollbackLastBatch() (*Plan, error)
+	PlanRollbackLastBatchContext(ctx context.Context) (*Plan, error)
+	Status() ([]MigrationStatus, error)
+	StatusContext(ctx context.Context) ([]MigrationStatus, error)
+	Validate() error
+	ValidateContext(ctx context.Context) error
+}
+
 // migrater is basic struct to help
 // to make all migrations
 //
 // counter is set during migration
 type migrater struct {
-	counter uint
-	mongo   *MongoMigrater
-	table   string
+	counter          uint
+	driver           Driver
+	mongo            *MongoMigrater
+	migrations       []Migration
+	lockTimeout      time.Duration
+	migrationTimeout time.Duration
+	batch            int
+	table            string
+	sqlStore         *sql.DB
+	logger           Logger
+	hooks            []Hooks
+	metrics          Metrics
+	tracer           Tracer
 }
 
+var _ Migrater = (*migrater)(nil)
+
 func NewMigrater() *migrater {
+	mgo := NewMongoMigrater()
 	return &migrater{
-		counter: 0,
-		mongo:   NewMongoMigrater(),
+		counter:     0,
+		driver:      mgo,
+		mongo:       mgo,
+		migrations:  []Migration{},
+		lockTimeout: DefaultLockTimeout,
+		logger:      nopLogger{},
+		tracer:      nopTracer{},
+	}
+}
+
+// AddMigration registers migration keeping
+// migrations sorted by timestamp. Migration with
+// already registered timestamp replaces the old one
+func (m *migrater) AddMigration(mgtn Migration) {
+	migrations := m.migrations
+	i := sort.Search(len(migrations), func(i int) bool {
+		return migrations[i].GetTimestamp() >= mgtn.GetTimestamp()
+	})
+	if i < len(migrations) && migrations[i].GetTimestamp() == mgtn.GetTimestamp() {
+		migrations[i] = mgtn
+		return
 	}
+	migrations = append(migrations, nil)
+	copy(migrations[i+1:], migrations[i:])
+	migrations[i] = mgtn
+	m.migrations = migrations
 }
 
 func (m *migrater) AddMongoMigration(mgtn MongoMigration) {
-	st := strconv.FormatUint(mgtn.Timestamp, 10)
-	m.mongo.migrations[st] = mgtn
+	m.AddMigration(mgtn)
 }
 
+func (m *migrater) AddSQLMigration(mgtn SQLMigration) {
+	m.AddMigration(mgtn)
+}
+
+// SetDriver sets driver used to run migrations.
+// Table set by SetTable is passed to drivers
+// which have SetTable method
+func (m *migrater) SetDriver(driver Driver) {
+	m.driver = driver
+	m.configureDriver()
+}
+
+// SetMongoDatabase sets database for
+// mongo migrations and makes mongo the driver
 func (m *migrater) SetMongoDatabase(db *mongo.Database) {
 	m.mongo.db = db
+	m.SetDriver(m.mongo)
+}
+
+// SetMongoTransactions enables running every mongo
+// migration and its bookkeeping in one transaction.
+// Transactions require mongo replica set or sharded cluster
+func (m *migrater) SetMongoTransactions(enabled bool) {
+	m.mongo.transactions = enabled
+}
+
+// SetPostgresDatabase makes postgres the driver
+func (m *migrater) SetPostgresDatabase(db *sql.DB) {
+	m.SetDriver(NewPostgresMigrater(db))
+}
+
+// SetSQLiteDatabase makes sqlite the driver
+func (m *migrater) SetSQLiteDatabase(db *sql.DB) {
+	m.SetDriver(NewSQLiteMigrater(db))
+}
+
+// SetMySQLDatabase makes mysql the driver
+func (m *migrater) SetMySQLDatabase(db *sql.DB) {
+	m.SetDriver(NewMySQLMigrater(db))
+}
+
+// SetTable sets name of the table or the collection
+// keeping track of migrations. Empty name restores
+// schema_migrations for sql drivers and migrations for mongo
+func (m *migrater) SetTable(name string) {
+	m.table = name
+	m.mongo.SetTable(name)
+	m.configureDriver()
+}
+
+// SetMongoTrackingDatabase keeps track of mongo migrations
+// in db instead of the database set by SetMongoDatabase.
+// Transactions require db of the same client
+func (m *migrater) SetMongoTrackingDatabase(db *mongo.Database) {
+	m.mongo.SetTrackingDatabase(db)
+}
+
+// SetSQLTrackingDatabase keeps track of sql migrations
+// in db instead of the database which is migrated
+func (m *migrater) SetSQLTrackingDatabase(db *sql.DB) {
+	m.sqlStore = db
+	m.configureDriver()
 }
 
+// configureDriver passes table and tracking
+// database to the driver
+func (m *migrater) configureDriver() {
+	if d, ok := m.driver.(interface{ SetTable(name string) }); ok {
+		d.SetTable(m.table)
+	}
+	if d, ok := m.driver.(interface{ SetTrackingDatabase(db *sql.DB) }); ok {
+		d.SetTrackingDatabase(m.sqlStore)
+	}
+}
+
+// SetLockTimeout sets how long Run and Rollback
+// wait for the lock held by other process
+func (m *migrater) SetLockTimeout(timeout time.Duration) {
+	m.lockTimeout = timeout
+}
+
+// SetMigrationTimeout limits time of every single
+// migration. Zero means no limit
+func (m *migrater) SetMigrationTimeout(timeout time.Duration) {
+	m.migrationTimeout = timeout
+}
+
+// Run applies pending migrations
+// in ascending timestamp order
 func (m *migrater) Run() error {
-	// run mongo migrations
-	for _, migration := range m.mongo.migrations {
-		// check if migration was called before
-		if m.mongo.IsMigrated(migration.Timestamp) {
-			continue
-		}
-		err := migration.Up(m.mongo.db)
-		if err != nil {
+	return m.RunContext(context.Background())
+}
+
+// RunContext is like Run, but stops when ctx is done.
+// ctx is passed to the driver and migration code
+func (m *migrater) RunContext(ctx context.Context) error {
+	err := m.withLock(ctx, "Run", func(ctx context.Context, applied map[uint64]MigrationRecord) error {
+		if err := m.validate(applied); err != nil {
 			return err
 		}
-		// increment counter
-		m.counter++
-		// save information about migration to database
-		en := &MongoMigrationEntity{
-			Timestamp:   migration.Timestamp,
-			Description: migration.Description,
-			Migrated:    time.Now(),
+		_, err := m.executePlan(ctx, m.planRun(m.migrations, applied))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if m.counter == 0 {
+		m.logger.Info("There was nothing to migrate")
+	}
+	return nil
+}
+
+// Rollback reverts applied migrations
+// in descending timestamp order
+func (m *migrater) Rollback(timestamps ...string) error {
+	return m.RollbackContext(context.Background(), timestamps...)
+}
+
+// RollbackContext is like Rollback, but stops when ctx is done.
+// ctx is passed to the driver and migration code
+func (m *migrater) RollbackContext(ctx context.Context, timestamps ...string) error {
+	migrations, err := m.reduceMigrations(timestamps...)
+	if err != nil {
+		return err
+	}
+
+	err = m.withLock(ctx, "Rollback", func(ctx context.Context, applied map[uint64]MigrationRecord) error {
+		_, err := m.executePlan(ctx, m.planRollback(migrations, applied))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if m.counter == 0 {
+		m.logger.Info("There was nothing to rollback")
+	}
+	return nil
+}
+
+// RollbackLastBatch reverts migrations applied
+// by the latest Run, MigrateTo or Steps call
+func (m *migrater) RollbackLastBatch() error {
+	return m.RollbackLastBatchContext(context.Background())
+}
+
+func (m *migrater) RollbackLastBatchContext(ctx context.Context) error {
+	err := m.withLock(ctx, "RollbackLastBatch", func(ctx context.Context, applied map[uint64]MigrationRecord) error {
+		_, err := m.executePlan(ctx, m.planLastBatch(applied))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if m.counter == 0 {
+		m.logger.Info("There was nothing to rollback")
+	}
+	return nil
+}
+
+// MigrateTo moves database to the version of migration
+// with passed timestamp. Applied migrations newer than
+// the target are reverted, pending ones up to
+// the target are applied
+func (m *migrater) MigrateTo(timestamp string) error {
+	return m.MigrateToContext(context.Background(), timestamp)
+}
+
+func (m *migrater) MigrateToContext(ctx context.Context, timestamp string) error {
+	i := m.findMigration(timestamp)
+	if i < 0 {
+		return migrationNotFoundError(timestamp)
+	}
+	older, newer := m.migrations[:i+1], m.migrations[i+1:]
+
+	err := m.withLock(ctx, "MigrateTo", func(ctx context.Context, applied map[uint64]MigrationRecord) error {
+		if err := m.validate(applied); err != nil {
+			return err
 		}
-		err = m.mongo.SaveMigration(en)
-		if err != nil {
+		if _, err := m.executePlan(ctx, m.planRollback(newer, applied)); err != nil {
 			return err
 		}
-		log.Printf("Migration %d (%s) succeded", migration.Timestamp, migration.Description)
+		_, err := m.executePlan(ctx, m.planRun(older, applied))
+		return err
+	})
+	if err != nil {
+		return err
 	}
 	if m.counter == 0 {
-		log.Println("There was nothing to migrate")
+		m.logger.Info("Database is already at the migration", "timestamp", timestamp)
 	}
 	return nil
 }
 
-func (m *migrater) Rollback(timestamps ...string) error {
-	err := m.reduceMigrations(timestamps...)
+// Steps applies n next pending migrations when n is positive
+// or reverts -n last applied migrations when n is negative
+func (m *migrater) Steps(n int) error {
+	return m.StepsContext(context.Background(), n)
+}
+
+func (m *migrater) StepsContext(ctx context.Context, n int) error {
+	err := m.withLock(ctx, "Steps", func(ctx context.Context, applied map[uint64]MigrationRecord) error {
+		if n > 0 {
+			if err := m.validate(applied); err != nil {
+				return err
+			}
+		}
+		_, err := m.executePlan(ctx, m.planSteps(n, applied))
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	if m.counter == 0 {
+		m.logger.Info("There was nothing to migrate")
+	}
+	return nil
+}
+
+// Redo reverts the last applied migration
+// and applies it again
+func (m *migrater) Redo() error {
+	return m.RedoContext(context.Background())
+}
 
-	for _, migration := range m.mongo.migrations {
-		err := m.rollbackOne(migration)
+func (m *migrater) RedoContext(ctx context.Context) error {
+	err := m.withLock(ctx, "Redo", func(ctx context.Context, applied map[uint64]MigrationRecord) error {
+		reverted, err := m.executePlan(ctx, m.planSteps(-1, applied))
 		if err != nil {
 			return err
 		}
+		// migration skipped by hook is still applied
+		if len(reverted) == 0 {
+			return nil
+		}
+		// redone migration stays in its release batch
+		m.batch = applied[reverted[0].GetTimestamp()].Batch
+		_, err = m.executePlan(ctx, &Plan{Direction: Up, Migrations: reverted})
+		return err
+	})
+	if err != nil {
+		return err
 	}
-
 	if m.counter == 0 {
-		log.Println("There was nothing to rollback")
+		m.logger.Info("There was nothing to redo")
 	}
 	return nil
 }
 
-func (m *migrater) rollbackOne(migration MongoMigration) error {
-	if m.mongo.IsMigrated(migration.Timestamp) {
-		err := migration.Down(m.mongo.db)
-		if err != nil {
+// Resolve clears dirty state of migration with passed
+// timestamp after the database was fixed manually.
+// When applied is true migration is kept as applied,
+// otherwise it is forgotten and will be run again
+func (m *migrater) Resolve(timestamp string, applied bool) error {
+	return m.ResolveContext(context.Background(), timestamp, applied)
+}
+
+func (m *migrater) ResolveContext(ctx context.Context, timestamp string, applied bool) error {
+	ts, err := strconv.ParseUint(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid timestamp `%s`", timestamp)
+	}
+	rd, ok := m.driver.(ResolvingDriver)
+	if !ok {
+		return fmt.Errorf("Driver %T does not track dirty migrations", m.driver)
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	records, err := m.driver.Applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if rec.Timestamp != ts {
+			continue
+		}
+		if !rec.Dirty {
+			return fmt.Errorf("Migration %d is not dirty", ts)
+		}
+		if err := rd.Resolve(ctx, ts, applied); err != nil {
 			return err
 		}
-		// increment counter
-		m.counter++
-		err = m.mongo.DeleteMigration(migration.Timestamp)
+		m.logger.Info("Migration resolved", "timestamp", ts, "applied", applied)
+		return nil
+	}
+	return migrationNotFoundError(timestamp)
+}
+
+// withLock calls fn with applied migrations
+// while holding driver lock. Migrations applied
+// by fn get the next batch number. ctx passed
+// to fn carries span of the command
+func (m *migrater) withLock(ctx context.Context, command string, fn func(ctx context.Context, applied map[uint64]MigrationRecord) error) (err error) {
+	ctx, span := m.tracer.Start(ctx, "migrater."+command)
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	m.batch = lastBatch(applied) + 1
+	err = fn(ctx, applied)
+	m.reportVersion()
+	return err
+}
+
+// executePlan applies or reverts planned migrations
+// calling hooks around them. It returns migrations which
+// were executed, so skipped ones are not included
+func (m *migrater) executePlan(ctx context.Context, plan *Plan) ([]Migration, error) {
+	executed := []Migration{}
+	if len(plan.Migrations) == 0 {
+		return executed, nil
+	}
+	start := time.Now()
+	if err := m.beforeAll(ctx, plan); err != nil {
+		return executed, err
+	}
+	for _, migration := range plan.Migrations {
+		// do not start next migration when ctx is done
+		if err := ctx.Err(); err != nil {
+			return executed, err
+		}
+		ok, err := m.beforeEach(ctx, migration, plan.Direction)
 		if err != nil {
-			return err
+			return executed, &MigrationError{
+				Timestamp:   migration.GetTimestamp(),
+				Description: migration.GetDescription(),
+				Direction:   plan.Direction,
+				Err:         err,
+			}
 		}
+		if !ok {
+			m.logger.Info("Migration skipped", "timestamp", migration.GetTimestamp(), "description", migration.GetDescription())
+			continue
+		}
+		if plan.Direction == Down {
+			err = m.rollbackOne(ctx, migration)
+		} else {
+			err = m.runOne(ctx, migration)
+		}
+		if err != nil {
+			return executed, err
+		}
+		executed = append(executed, migration)
+	}
+	m.afterAll(ctx, plan, time.Since(start))
+	return executed, nil
+}
 
-		log.Printf("Rollback migration %d (%s) succeded", migration.Timestamp, migration.Description)
+func (m *migrater) runOne(ctx context.Context, migration Migration) error {
+	// execute and save information about migration to database
+	rec := MigrationRecord{
+		Timestamp:   migration.GetTimestamp(),
+		Description: migration.GetDescription(),
+		Migrated:    time.Now(),
+		Batch:       m.batch,
+		Checksum:    checksum(migration),
 	}
+	err := m.executeSpan(ctx, migration, Up, rec)
+	m.finish(ctx, MigrationEvent{Migration: migration, Direction: Up, Duration: time.Since(rec.Migrated), Err: err})
+	if err != nil {
+		return &MigrationError{Timestamp: rec.Timestamp, Description: rec.Description, Direction: Up, Err: err}
+	}
+	// increment counter
+	m.counter++
+	return nil
+}
+
+func (m *migrater) rollbackOne(ctx context.Context, migration Migration) error {
+	rec := MigrationRecord{
+		Timestamp:   migration.GetTimestamp(),
+		Description: migration.GetDescription(),
+	}
+	start := time.Now()
+	err := m.executeSpan(ctx, migration, Down, rec)
+	m.finish(ctx, MigrationEvent{Migration: migration, Direction: Down, Duration: time.Since(start), Err: err})
+	if err != nil {
+		return &MigrationError{Timestamp: rec.Timestamp, Description: rec.Description, Direction: Down, Err: err}
+	}
+	// increment counter
+	m.counter++
 	return nil
 }
 
-func (m *migrater) reduceMigrations(timestamps ...string) error {
+// finish logs outcome of applied or reverted
+// migration and calls hooks
+func (m *migrater) finish(ctx context.Context, event MigrationEvent) {
+	args := []interface{}{
+		"timestamp", event.Migration.GetTimestamp(),
+		"description", event.Migration.GetDescription(),
+		"direction", event.Direction.String(),
+		"duration", event.Duration,
+	}
+	if event.Err != nil {
+		m.logger.Error("Migration failed", append(args, "outcome", "failure", "error", event.Err)...)
+	} else {
+		m.logger.Info("Migration succeeded", append(args, "outcome", "success")...)
+	}
+	if m.metrics != nil {
+		m.metrics.ObserveMigration(event)
+	}
+	m.afterEach(ctx, event)
+}
+
+// lock takes driver lock and returns function releasing it
+func (m *migrater) lock(ctx context.Context) (func(), error) {
+	start := time.Now()
+	err := m.driver.Lock(ctx, m.lockTimeout)
+	if m.metrics != nil {
+		m.metrics.ObserveLockWait(time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		// lock has to be released even when ctx is cancelled
+		if err := m.driver.Unlock(context.Background()); err != nil {
+			m.logger.Error("Unable to release migrations lock", "error", err)
+		}
+	}, nil
+}
+
+// execute runs migration and records it or removes
+// its record. Transactional drivers do both in one transaction
+func (m *migrater) execute(ctx context.Context, migration Migration, direction Direction, rec MigrationRecord) error {
+	if m.migrationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.migrationTimeout)
+		defer cancel()
+	}
+	if td, ok := m.driver.(TransactionalDriver); ok {
+		return td.ExecuteTx(ctx, migration, direction, rec)
+	}
+	err := m.driver.Execute(ctx, migration, direction)
+	if err != nil {
+		return err
+	}
+	if direction == Down {
+		return m.driver.Remove(ctx, rec.Timestamp)
+	}
+	return m.driver.Record(ctx, rec)
+}
+
+// applied returns records of already applied
+// migrations by timestamp. It fails
+// when any migration is dirty
+func (m *migrater) applied(ctx context.Context) (map[uint64]MigrationRecord, error) {
+	records, err := m.driver.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]MigrationRecord, len(records))
+	for _, rec := range records {
+		if rec.Dirty {
+			return nil, dirtyError(rec)
+		}
+		applied[rec.Timestamp] = rec
+	}
+	return applied, nil
+}
+
+// lastBatch returns the highest batch number
+// of applied migrations
+func lastBatch(applied map[uint64]MigrationRecord) int {
+	last := 0
+	for _, rec := range applied {
+		if rec.Batch > last {
+			last = rec.Batch
+		}
+	}
+	return last
+}
+
+// reduceMigrations returns registered migrations
+// with passed timestamps or all of them when
+// no timestamp is passed
+func (m *migrater) reduceMigrations(timestamps ...string) ([]Migration, error) {
 	if len(timestamps) == 0 {
-		return nil
+		return m.migrations, nil
 	}
-	new := make(map[string]MongoMigration)
+	selected := make(map[string]bool)
 
 	for _, t := range timestamps {
-		if migration, ok := m.mongo.migrations[t]; ok {
-			st := strconv.FormatUint(migration.Timestamp, 10)
-			new[st] = migration
-		} else {
-			return fmt.Errorf("Migration with timestamp: `%s` does not exist or has not been added to migrations map.", t)
+		if m.findMigration(t) < 0 {
+			return nil, migrationNotFoundError(t)
 		}
+		selected[t] = true
 	}
 
-	m.mongo.migrations = new
+	reduced := make([]Migration, 0, len(selected))
+	for _, migration := range m.migrations {
+		st := strconv.FormatUint(migration.GetTimestamp(), 10)
+		if selected[st] {
+			reduced = append(reduced, migration)
+		}
+	}
 
-	return nil
+	return reduced, nil
+}
+
+// findMigration returns index of migration
+// with passed timestamp or -1 if it is not registered
+func (m *migrater) findMigration(timestamp string) int {
+	for i, migration := range m.migrations {
+		if strconv.FormatUint(migration.GetTimestamp(), 10) == timestamp {
+			return i
+		}
+	}
+	return -1
 }
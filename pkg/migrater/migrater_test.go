@@ -19,7 +19,7 @@ import (
 
 func TestEnv(t *testing.T) {
 	if len(os.Getenv("MONGO_HOST")) == 0 {
-		t.Fatal("MONGO_HOST env variable is not set properly")
+		t.Skip("MONGO_HOST env variable is not set, mongo tests are skipped")
 	}
 	if len(os.Getenv("MONGO_PORT")) == 0 {
 		t.Fatal("MONGO_PORT env variable is not set properly")
@@ -45,17 +45,36 @@ func TestAddMongoMigration(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
 	m.AddMongoMigration(mig)
 
-	if len(m.mongo.migrations) == 0 {
-		t.Fatal("Expected", len(m.mongo.migrations), "Got", 0)
+	if len(m.migrations) == 0 {
+		t.Fatal("Expected", len(m.migrations), "Got", 0)
+	}
+}
+
+func TestAddMongoMigrationOrder(t *testing.T) {
+	m := NewMigrater()
+	for _, ts := range []uint64{30, 10, 20, 10} {
+		m.AddMongoMigration(MongoMigration{
+			Timestamp:   ts,
+			Description: "Your description",
+		})
+	}
+
+	if len(m.migrations) != 3 {
+		t.Fatal("Expected", 3, "Got", len(m.migrations))
+	}
+	for i, ts := range []uint64{10, 20, 30} {
+		if m.migrations[i].GetTimestamp() != ts {
+			t.Fatal("Expected", ts, "Got", m.migrations[i].GetTimestamp())
+		}
 	}
 }
 
@@ -92,10 +111,10 @@ func TestRun(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -118,10 +137,10 @@ func TestRunAndIsMigrated(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -156,10 +175,10 @@ func TestRunError(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return errors.New("Testing purpose error")
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -192,10 +211,10 @@ func TestRunSaveMigrationError(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -207,10 +226,10 @@ func TestRunSaveMigrationError(t *testing.T) {
 	migWithDescriptionErr := MongoMigration{
 		Timestamp:   uint64(20060102150405),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -232,10 +251,10 @@ func TestRollback(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -265,10 +284,10 @@ func TestRollbackError(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return errors.New("Testing purpose error")
 		},
 	}
@@ -292,10 +311,10 @@ func TestRollbackDeleteMigrationError(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -313,6 +332,9 @@ func TestRollbackDeleteMigrationError(t *testing.T) {
 	guard = monkey.PatchInstanceMethod(reflect.TypeOf(c), "DeleteOne",
 		func(c *mongo.Collection, ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
 			log.Printf("record: %+v, collection: %s, database: %s", filter, c.Name(), c.Database().Name())
+			// only removing the record fails,
+			// so the lock is released
+			guard.Unpatch()
 			return nil, errors.New("Test error")
 		})
 	defer guard.Unpatch()
@@ -334,20 +356,20 @@ func TestRollbackWithReduce(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
 	mig2 := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description second",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -381,10 +403,10 @@ func TestRollbackWithReduceBadTimestampError(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -404,3 +426,39 @@ func TestRollbackWithReduceBadTimestampError(t *testing.T) {
 	// clear migrations
 	db.Collection("migrations").DeleteMany(ctx, bson.D{})
 }
+
+func TestRunAndRollbackOrder(t *testing.T) {
+	m := NewMigrater()
+	ctx := context.Background()
+	db := connectMongo(t)
+	m.SetMongoDatabase(db)
+
+	var calls []string
+	for _, ts := range []uint64{3, 1, 2} {
+		st := strconv.FormatUint(ts, 10)
+		m.AddMongoMigration(MongoMigration{
+			Timestamp:   ts,
+			Description: "Your description",
+			Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
+				calls = append(calls, "up"+st)
+				return nil
+			},
+			Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
+				calls = append(calls, "down"+st)
+				return nil
+			},
+		})
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"up1", "up2", "up3", "down3", "down2", "down1"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatal("Expected", expected, "Got", calls)
+	}
+	// clear migrations table
+	db.Collection("migrations").DeleteMany(ctx, bson.D{})
+}
@@ -3,114 +3,370 @@ package migrater
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
-	"text/template"
 	"time"
 
-	"github.com/malekim/migrater/internal/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var mongoStub string = `
-package migrations
+package {{ .Package }}
 import (
 	"github.com/malekim/migrater/pkg/migrater"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var Migration{{ .Timestamp }} migrater.MongoMigration = migrater.MongoMigration{
-	Timestamp:     {{ .Timestamp }},
-	Description: "Your description",
-	Up: func(db *mongo.Database) error {
+	Timestamp:   {{ .Timestamp }},
+	Description: {{ printf "%q" .Description }},
+	// change checksum whenever Up or Down is changed
+	Checksum: "1",
+	Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 		return nil
 	},
-	Down: func(db *mongo.Database) error {
+	Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 		return nil
 	},
 }
 `
 
+const (
+	mongoLockID  = "migrations"
+	mongoLockTTL = 30 * time.Second
+	// mongoCollection is a default name of
+	// collection keeping track of migrations
+	mongoCollection = "migrations"
+)
+
+// MongoMigrater is a Driver which runs
+// migrations against mongo database
+//
+// When transactions are enabled migration is executed
+// in a transaction together with its bookkeeping.
+// Applied migrations are kept in the collection
+// of store database, which is db unless set
 type MongoMigrater struct {
-	counter    uint
-	migrations map[string]MongoMigration
-	db         *mongo.Database
+	db            *mongo.Database
+	store         *mongo.Database
+	collection    string
+	transactions  bool
+	lockOwner     string
+	stopHeartbeat chan struct{}
+	heartbeatDone chan struct{}
 }
 
-type MongoMigrationFunc func(db *mongo.Database) error
+// MongoMigrationFunc is called inside a session.
+// sctx carries context passed to RunContext or
+// RollbackContext. Operations should be called
+// with sctx, so they take part in the transaction
+// when it is enabled
+type MongoMigrationFunc func(sctx mongo.SessionContext, db *mongo.Database) error
 
+// MongoMigration is a migration for mongo driver.
+// Checksum is optional, see Checksummer
 type MongoMigration struct {
 	Timestamp   uint64
 	Description string
+	Checksum    string
 	Up          MongoMigrationFunc
 	Down        MongoMigrationFunc
 }
 
+func (mgtn MongoMigration) GetTimestamp() uint64 {
+	return mgtn.Timestamp
+}
+
+func (mgtn MongoMigration) GetDescription() string {
+	return mgtn.Description
+}
+
+func (mgtn MongoMigration) GetChecksum() string {
+	return mgtn.Checksum
+}
+
 type MongoMigrationEntity struct {
 	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	Timestamp   uint64             `json:"timestamp" bson:"timestamp"`
 	Description string             `json:"description" bson:"description"`
 	Migrated    time.Time          `json:"migrated" bson:"migrated"`
+	Batch       int                `json:"batch" bson:"batch"`
+	Checksum    string             `json:"checksum,omitempty" bson:"checksum,omitempty"`
 }
 
 func NewMongoMigrater() *MongoMigrater {
-	return &MongoMigrater{
-		counter:    0,
-		migrations: make(map[string]MongoMigration),
+	return &MongoMigrater{collection: mongoCollection}
+}
+
+// SetTable sets name of the collection keeping
+// track of migrations. Lock is kept in the
+// collection with _lock suffix
+func (mgo *MongoMigrater) SetTable(name string) {
+	if name == "" {
+		name = mongoCollection
 	}
+	mgo.collection = name
 }
 
-func (mgo *MongoMigrater) IsMigrated(timestamp uint64) bool {
-	en := &MongoMigrationEntity{}
-	collection := mgo.db.Collection("migrations")
-	err := collection.FindOne(context.TODO(), bson.M{"timestamp": timestamp}).Decode(&en)
+// SetTrackingDatabase keeps track of migrations in db
+// instead of the database which is migrated. With transactions
+// enabled db has to come from the same client
+func (mgo *MongoMigrater) SetTrackingDatabase(db *mongo.Database) {
+	mgo.store = db
+}
+
+// migrations returns collection keeping
+// track of migrations
+func (mgo *MongoMigrater) migrations() *mongo.Collection {
+	return mgo.storeDatabase().Collection(mgo.collection)
+}
+
+// locks returns collection keeping the lock
+func (mgo *MongoMigrater) locks() *mongo.Collection {
+	return mgo.storeDatabase().Collection(mgo.collection + "_lock")
+}
+
+func (mgo *MongoMigrater) storeDatabase() *mongo.Database {
+	if mgo.store != nil {
+		return mgo.store
+	}
+	return mgo.db
+}
+
+// Lock inserts lock document to migrations_lock collection.
+// Lock expires after mongoLockTTL unless it is extended
+// by heartbeat, so crashed process does not keep it forever
+func (mgo *MongoMigrater) Lock(ctx context.Context, wait time.Duration) error {
+	owner := lockOwner()
+	collection := mgo.locks()
+	err := acquireLock(ctx, wait, func() (bool, error) {
+		now := time.Now()
+		// take over expired lock or insert a new one,
+		// upsert fails on _id when lock is held by other process
+		_, err := collection.UpdateOne(
+			ctx,
+			bson.M{"_id": mongoLockID, "expires": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{
+				"owner":    owner,
+				"acquired": now,
+				"expires":  now.Add(mongoLockTTL),
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if isDuplicateKeyError(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
 	if err != nil {
-		fmt.Println(err.Error())
-		return false
+		return err
 	}
-	return true
+	mgo.lockOwner = owner
+	mgo.stopHeartbeat = make(chan struct{})
+	mgo.heartbeatDone = make(chan struct{})
+	go mgo.heartbeat(owner, mgo.stopHeartbeat, mgo.heartbeatDone)
+	return nil
 }
 
-func (mgo *MongoMigrater) SaveMigration(en *MongoMigrationEntity) error {
-	collection := mgo.db.Collection("migrations")
-	_, err := collection.InsertOne(context.TODO(), en)
-	return err
+// heartbeat extends the lock until stop is closed.
+// It does not use context of Lock, because lock
+// has to be kept until Unlock is called
+func (mgo *MongoMigrater) heartbeat(owner string, stop, done chan struct{}) {
+	defer close(done)
+	collection := mgo.locks()
+	ticker := time.NewTicker(mongoLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			collection.UpdateOne(
+				context.Background(),
+				bson.M{"_id": mongoLockID, "owner": owner},
+				bson.M{"$set": bson.M{"expires": time.Now().Add(mongoLockTTL)}},
+			)
+		}
+	}
 }
 
-func (mgo *MongoMigrater) DeleteMigration(timestamp uint64) error {
-	collection := mgo.db.Collection("migrations")
-	_, err := collection.DeleteOne(context.TODO(), bson.M{"timestamp": timestamp})
+func (mgo *MongoMigrater) Unlock(ctx context.Context) error {
+	if mgo.stopHeartbeat == nil {
+		return nil
+	}
+	close(mgo.stopHeartbeat)
+	<-mgo.heartbeatDone
+	mgo.stopHeartbeat = nil
+	collection := mgo.locks()
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": mongoLockID, "owner": mgo.lockOwner})
 	return err
 }
 
-func AddMongoMigrationFile() error {
-	timestamp := time.Now().Unix()
-	name := fmt.Sprintf("%d.go", timestamp)
-	t := template.Must(template.New("").Parse(mongoStub))
-	path := filepath.Join("app", "migrations", name)
-	if err := utils.EnsureDir(path); err != nil {
-		log.Printf("Error creating dir: %s", err.Error())
+func (mgo *MongoMigrater) Applied(ctx context.Context) ([]MigrationRecord, error) {
+	collection := mgo.migrations()
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	entities := []MongoMigrationEntity{}
+	if err := cursor.All(ctx, &entities); err != nil {
+		return nil, err
+	}
+	records := make([]MigrationRecord, 0, len(entities))
+	for _, en := range entities {
+		records = append(records, MigrationRecord{
+			Timestamp:   en.Timestamp,
+			Description: en.Description,
+			Migrated:    en.Migrated,
+			Batch:       en.Batch,
+			Checksum:    en.Checksum,
+		})
+	}
+	return records, nil
+}
+
+func (mgo *MongoMigrater) Record(ctx context.Context, rec MigrationRecord) error {
+	return mgo.SaveMigration(ctx, &MongoMigrationEntity{
+		Timestamp:   rec.Timestamp,
+		Description: rec.Description,
+		Migrated:    rec.Migrated,
+		Batch:       rec.Batch,
+		Checksum:    rec.Checksum,
+	})
+}
+
+func (mgo *MongoMigrater) Remove(ctx context.Context, timestamp uint64) error {
+	return mgo.DeleteMigration(ctx, timestamp)
+}
+
+// Execute calls migration inside a session
+// created from ctx, so migration code gets its deadline
+func (mgo *MongoMigrater) Execute(ctx context.Context, mgtn Migration, direction Direction) error {
+	fn, err := mongoMigrationFunc(mgtn, direction)
+	if err != nil {
 		return err
 	}
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	return mgo.db.Client().UseSession(ctx, func(sctx mongo.SessionContext) error {
+		return fn(sctx, mgo.db)
+	})
+}
+
+// ExecuteTx executes migration and its bookkeeping in one
+// transaction when transactions are enabled, otherwise
+// they are called one after another
+func (mgo *MongoMigrater) ExecuteTx(ctx context.Context, mgtn Migration, direction Direction, rec MigrationRecord) error {
+	if !mgo.transactions {
+		if err := mgo.Execute(ctx, mgtn, direction); err != nil {
+			return err
+		}
+		if direction == Down {
+			return mgo.Remove(ctx, rec.Timestamp)
+		}
+		return mgo.Record(ctx, rec)
+	}
+	// session of one client cannot write through another
+	if mgo.store != nil && mgo.store.Client() != mgo.db.Client() {
+		return ErrTrackingClient
+	}
+	fn, err := mongoMigrationFunc(mgtn, direction)
 	if err != nil {
-		log.Printf("Error opening file: %s", err.Error())
 		return err
 	}
-	defer f.Close()
+	// collection cannot be created inside a transaction
+	if err := mgo.ensureCollection(ctx); err != nil {
+		return err
+	}
+	return mgo.db.Client().UseSession(ctx, func(sctx mongo.SessionContext) error {
+		_, err := sctx.WithTransaction(sctx, func(sctx mongo.SessionContext) (interface{}, error) {
+			if err := fn(sctx, mgo.db); err != nil {
+				return nil, err
+			}
+			if direction == Down {
+				return nil, mgo.DeleteMigration(sctx, rec.Timestamp)
+			}
+			return nil, mgo.SaveMigration(sctx, &MongoMigrationEntity{
+				Timestamp:   rec.Timestamp,
+				Description: rec.Description,
+				Migrated:    rec.Migrated,
+				Batch:       rec.Batch,
+				Checksum:    rec.Checksum,
+			})
+		})
+		return err
+	})
+}
+
+// ensureCollection creates migrations collection
+// if it does not exist
+func (mgo *MongoMigrater) ensureCollection(ctx context.Context) error {
+	err := mgo.storeDatabase().RunCommand(ctx, bson.D{{Key: "create", Value: mgo.collection}}).Err()
+	if e, ok := err.(mongo.CommandError); ok && e.Code == 48 {
+		// NamespaceExists
+		return nil
+	}
+	return err
+}
 
-	vars := struct {
-		Timestamp int64
-	}{
-		timestamp,
+func mongoMigrationFunc(mgtn Migration, direction Direction) (MongoMigrationFunc, error) {
+	switch migration := mgtn.(type) {
+	case MongoMigration:
+		if direction == Down {
+			return migration.Down, nil
+		}
+		return migration.Up, nil
+	case MongoCommandMigration:
+		if direction == Down {
+			return runCommands(migration.Down), nil
+		}
+		return runCommands(migration.Up), nil
 	}
+	return nil, fmt.Errorf("Mongo driver cannot execute migration of type %T", mgtn)
+}
 
-	err = t.Execute(f, vars)
-	if err == nil {
-		log.Printf("Created %s\n", name)
+// IsMigrated checks if migration with timestamp is recorded.
+// Missing record is not an error, failed lookup is
+func (mgo *MongoMigrater) IsMigrated(ctx context.Context, timestamp uint64) (bool, error) {
+	en := &MongoMigrationEntity{}
+	collection := mgo.migrations()
+	err := collection.FindOne(ctx, bson.M{"timestamp": timestamp}).Decode(&en)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
+	return true, nil
+}
+
+func (mgo *MongoMigrater) SaveMigration(ctx context.Context, en *MongoMigrationEntity) error {
+	collection := mgo.migrations()
+	_, err := collection.InsertOne(ctx, en)
+	return err
+}
+
+func (mgo *MongoMigrater) DeleteMigration(ctx context.Context, timestamp uint64) error {
+	collection := mgo.migrations()
+	_, err := collection.DeleteOne(ctx, bson.M{"timestamp": timestamp})
 	return err
 }
+
+// isDuplicateKeyError checks if mongo
+// returned duplicate key error
+func isDuplicateKeyError(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == 11000 {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == 11000
+	}
+	return false
+}
+
+func AddMongoMigrationFile() error {
+	return addMigrationFile(mongoStub, GeneratorConfig{})
+}
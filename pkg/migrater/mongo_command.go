@@ -0,0 +1,98 @@
+package migrater
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/yaml.v2"
+)
+
+// MongoCommandMigration is a declarative mongo migration.
+// Up and Down are lists of commands passed to runCommand,
+// so the migration can be written in json or yaml
+// without go code
+type MongoCommandMigration struct {
+	Timestamp   uint64
+	Description string
+	Checksum    string
+	Up          []bson.D
+	Down        []bson.D
+}
+
+func (mgtn MongoCommandMigration) GetTimestamp() uint64 {
+	return mgtn.Timestamp
+}
+
+func (mgtn MongoCommandMigration) GetDescription() string {
+	return mgtn.Description
+}
+
+func (mgtn MongoCommandMigration) GetChecksum() string {
+	return mgtn.Checksum
+}
+
+// mongoCommandFile is a content of json or yaml migration file
+type mongoCommandFile struct {
+	Description string   `bson:"description"`
+	Up          []bson.D `bson:"up"`
+	Down        []bson.D `bson:"down"`
+}
+
+// runCommands returns migration function
+// running commands one after another
+func runCommands(commands []bson.D) MongoMigrationFunc {
+	return func(sctx mongo.SessionContext, db *mongo.Database) error {
+		for i, command := range commands {
+			if err := db.RunCommand(sctx, command).Err(); err != nil {
+				return fmt.Errorf("Command %d failed: %s", i+1, err.Error())
+			}
+		}
+		return nil
+	}
+}
+
+// parseMongoJSON reads migration file written
+// in mongo extended json
+func parseMongoJSON(data []byte) (mongoCommandFile, error) {
+	file := mongoCommandFile{}
+	err := bson.UnmarshalExtJSON(data, false, &file)
+	return file, err
+}
+
+// parseMongoYAML reads migration file written in yaml.
+// Order of keys is kept, because command name
+// has to be the first key of the command
+func parseMongoYAML(data []byte) (mongoCommandFile, error) {
+	file := mongoCommandFile{}
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return file, err
+	}
+	raw, err := bson.Marshal(yamlToBSON(doc))
+	if err != nil {
+		return file, err
+	}
+	err = bson.Unmarshal(raw, &file)
+	return file, err
+}
+
+// yamlToBSON converts decoded yaml value to bson
+// keeping order of keys in documents
+func yamlToBSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		doc := make(bson.D, 0, len(value))
+		for _, item := range value {
+			doc = append(doc, bson.E{Key: fmt.Sprint(item.Key), Value: yamlToBSON(item.Value)})
+		}
+		return doc
+	case []interface{}:
+		arr := make(bson.A, 0, len(value))
+		for _, item := range value {
+			arr = append(arr, yamlToBSON(item))
+		}
+		return arr
+	}
+	return v
+}
@@ -0,0 +1,173 @@
+package migrater
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var mongoJSONMigration = `{
+	"up": [
+		{"createIndexes": "users", "indexes": [{"key": {"email": 1}, "name": "email_1", "unique": true}]}
+	],
+	"down": [
+		{"dropIndexes": "users", "index": "email_1"}
+	]
+}`
+
+var mongoYAMLMigration = `description: Add users email index
+up:
+  - createIndexes: users
+    indexes:
+      - key:
+          email: 1
+        name: email_1
+        unique: true
+down:
+  - dropIndexes: users
+    index: email_1
+`
+
+func TestParseMongoFile(t *testing.T) {
+	fromJSON, err := parseMongoFile("1_add_users_email_index.json", []byte(mongoJSONMigration))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	fromYAML, err := parseMongoFile("1.yaml", []byte(mongoYAMLMigration))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if fromJSON.Timestamp != 1 || fromJSON.Description != "add users email index" {
+		t.Fatal("Unexpected migration", fromJSON)
+	}
+	if fromYAML.Description != "Add users email index" {
+		t.Fatal("Description should be read from file, Got", fromYAML.Description)
+	}
+	if fromJSON.Checksum == "" || fromJSON.Checksum == fromYAML.Checksum {
+		t.Fatal("Checksum should be computed from file")
+	}
+	for _, mgtn := range []MongoCommandMigration{fromJSON, fromYAML} {
+		if len(mgtn.Up) != 1 || len(mgtn.Down) != 1 {
+			t.Fatal("Unexpected commands", mgtn.Up, mgtn.Down)
+		}
+		// command name has to stay the first key
+		if mgtn.Up[0][0].Key != "createIndexes" || mgtn.Down[0][0].Key != "dropIndexes" {
+			t.Fatal("Unexpected order of keys", mgtn.Up, mgtn.Down)
+		}
+	}
+	// both formats give the same command
+	expected, got := bsonJSON(t, fromJSON.Up[0]), bsonJSON(t, fromYAML.Up[0])
+	if expected != got {
+		t.Fatal("Expected", expected, "Got", got)
+	}
+}
+
+func bsonJSON(t *testing.T, v interface{}) string {
+	data, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return string(data)
+}
+
+func TestParseMongoFileErrors(t *testing.T) {
+	if _, err := parseMongoFile("add_index.json", []byte(mongoJSONMigration)); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if _, err := parseMongoFile("1.json", []byte("{")); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if _, err := parseMongoFile("1.yml", []byte("up: [")); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	writeMigrationFile(t, dir, "2_second.yml", mongoYAMLMigration)
+	writeMigrationFile(t, dir, "1_first.json", mongoJSONMigration)
+	writeMigrationFile(t, dir, "registry.go", "package migrations")
+	writeMigrationFile(t, dir, ".migrater.yaml", "dir: .")
+
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 2 {
+		t.Fatal("Expected", 2, "Got", len(migrations))
+	}
+	m := NewMigrater()
+	for _, migration := range migrations {
+		m.AddMigration(migration)
+	}
+	if m.migrations[0].GetDescription() != "first" {
+		t.Fatal("Unexpected migration", m.migrations[0])
+	}
+
+	writeMigrationFile(t, dir, "third.json", mongoJSONMigration)
+	if _, err := LoadMigrations(dir); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestMongoMigrationFuncCommands(t *testing.T) {
+	migration := MongoCommandMigration{Timestamp: 1}
+	if _, err := mongoMigrationFunc(migration, Up); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := mongoMigrationFunc(SQLMigration{}, Up); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestRunMongoCommandMigration(t *testing.T) {
+	db := connectMongo(t)
+	ctx := context.Background()
+	db.Collection("users").Drop(ctx)
+	db.Collection("migrations").Drop(ctx)
+	defer db.Collection("users").Drop(ctx)
+	defer db.Collection("migrations").Drop(ctx)
+	if _, err := db.Collection("users").InsertOne(ctx, bson.M{"email": "a@example.com"}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	migration, err := parseMongoFile("1_email_index.json", []byte(mongoJSONMigration))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m := NewMigrater()
+	m.SetMongoDatabase(db)
+	m.AddMigration(migration)
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if countIndexes(t, db.Collection("users")) != 2 {
+		t.Fatal("Email index should be created")
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if countIndexes(t, db.Collection("users")) != 1 {
+		t.Fatal("Email index should be dropped")
+	}
+}
+
+func countIndexes(t *testing.T, collection *mongo.Collection) int {
+	cursor, err := collection.Indexes().List(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	indexes := []bson.M{}
+	if err := cursor.All(context.Background(), &indexes); err != nil {
+		t.Fatal(err.Error())
+	}
+	return len(indexes)
+}
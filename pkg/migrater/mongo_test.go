@@ -2,6 +2,7 @@ package migrater
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,7 +14,12 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// connectMongo skips the test when
+// mongo is not configured with env variables
 func connectMongo(t *testing.T) *mongo.Database {
+	if len(os.Getenv("MONGO_HOST")) == 0 {
+		t.Skip("MONGO_HOST env variable is not set")
+	}
 	mongoURI := fmt.Sprintf("mongodb://%s:%s", os.Getenv("MONGO_HOST"), os.Getenv("MONGO_PORT"))
 	ctx := context.Background()
 	clientOpts := options.Client().ApplyURI(mongoURI)
@@ -33,16 +39,19 @@ func TestIsMigrated(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
 	m.AddMongoMigration(mig)
 	m.Run()
-	isMigrated := m.mongo.IsMigrated(mig.Timestamp)
+	isMigrated, err := m.mongo.IsMigrated(context.Background(), mig.Timestamp)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
 	if !isMigrated {
 		t.Fatal("IsMigrated should return", true, "Got", false)
 	}
@@ -59,10 +68,10 @@ func TestSaveMigration(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -71,7 +80,7 @@ func TestSaveMigration(t *testing.T) {
 		Description: mig.Description,
 		Migrated:    time.Now(),
 	}
-	err := m.mongo.SaveMigration(en)
+	err := m.mongo.SaveMigration(ctx, en)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -93,10 +102,10 @@ func TestDeleteMigration(t *testing.T) {
 	mig := MongoMigration{
 		Timestamp:   uint64(time.Now().Unix()),
 		Description: "Your description",
-		Up: func(db *mongo.Database) error {
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
-		Down: func(db *mongo.Database) error {
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
 			return nil
 		},
 	}
@@ -105,7 +114,7 @@ func TestDeleteMigration(t *testing.T) {
 		Description: mig.Description,
 		Migrated:    time.Now(),
 	}
-	err := m.mongo.SaveMigration(en)
+	err := m.mongo.SaveMigration(ctx, en)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -117,7 +126,7 @@ func TestDeleteMigration(t *testing.T) {
 	if err != nil {
 		t.Fatal(err.Error())
 	}
-	err = m.mongo.DeleteMigration(en.Timestamp)
+	err = m.mongo.DeleteMigration(ctx, en.Timestamp)
 	if err != nil {
 		t.Fatal(err.Error())
 	}
@@ -132,6 +141,168 @@ func TestDeleteMigration(t *testing.T) {
 	collection.DeleteMany(ctx, bson.D{})
 }
 
+// skipWithoutReplicaSet skips the test when mongo
+// is standalone server, which does not support transactions
+func skipWithoutReplicaSet(t *testing.T, db *mongo.Database) {
+	res := bson.M{}
+	err := db.RunCommand(context.Background(), bson.M{"isMaster": 1}).Decode(&res)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := res["setName"]; !ok {
+		t.Skip("Mongo is not a replica set, transactions are not supported")
+	}
+}
+
+func TestSetMongoTransactions(t *testing.T) {
+	m := NewMigrater()
+	if m.mongo.transactions {
+		t.Fatal("Transactions should be disabled by default")
+	}
+	m.SetMongoTransactions(true)
+	if !m.mongo.transactions {
+		t.Fatal("Transactions should be enabled")
+	}
+}
+
+func TestRunWithTransactions(t *testing.T) {
+	m := NewMigrater()
+	ctx := context.Background()
+	db := connectMongo(t)
+	skipWithoutReplicaSet(t, db)
+	m.SetMongoDatabase(db)
+	m.SetMongoTransactions(true)
+
+	mig := MongoMigration{
+		Timestamp:   uint64(time.Now().Unix()),
+		Description: "Your description",
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
+			_, err := db.Collection("transactions_test").InsertOne(sctx, bson.M{"test": 1})
+			return err
+		},
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
+			_, err := db.Collection("transactions_test").DeleteMany(sctx, bson.M{})
+			return err
+		},
+	}
+	m.AddMongoMigration(mig)
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	isMigrated, err := m.mongo.IsMigrated(context.Background(), mig.Timestamp)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !isMigrated {
+		t.Fatal("IsMigrated should return", true, "Got", false)
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	isMigrated, err = m.mongo.IsMigrated(context.Background(), mig.Timestamp)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if isMigrated {
+		t.Fatal("IsMigrated should return", false, "Got", true)
+	}
+	db.Collection("transactions_test").Drop(ctx)
+	db.Collection("migrations").DeleteMany(ctx, bson.D{})
+}
+
+func TestRunWithTransactionsError(t *testing.T) {
+	m := NewMigrater()
+	ctx := context.Background()
+	db := connectMongo(t)
+	skipWithoutReplicaSet(t, db)
+	m.SetMongoDatabase(db)
+	m.SetMongoTransactions(true)
+	// collection cannot be created inside a transaction
+	db.RunCommand(ctx, bson.M{"create": "transactions_test"})
+
+	mig := MongoMigration{
+		Timestamp:   uint64(time.Now().Unix()),
+		Description: "Your description",
+		Up: func(sctx mongo.SessionContext, db *mongo.Database) error {
+			_, err := db.Collection("transactions_test").InsertOne(sctx, bson.M{"test": 1})
+			if err != nil {
+				return err
+			}
+			return errors.New("Testing purpose error")
+		},
+		Down: func(sctx mongo.SessionContext, db *mongo.Database) error {
+			return nil
+		},
+	}
+	m.AddMongoMigration(mig)
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	// insert should be aborted together with bookkeeping
+	count, err := db.Collection("transactions_test").CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 0 {
+		t.Fatal("Documents count should be", 0, "Got", count)
+	}
+	if isMigrated, _ := m.mongo.IsMigrated(context.Background(), mig.Timestamp); isMigrated {
+		t.Fatal("IsMigrated should return", false, "Got", true)
+	}
+	db.Collection("transactions_test").Drop(ctx)
+	db.Collection("migrations").DeleteMany(ctx, bson.D{})
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	db := connectMongo(t)
+	first := NewMongoMigrater()
+	first.db = db
+	second := NewMongoMigrater()
+	second.db = db
+
+	if err := first.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Lock(context.Background(), time.Second); err == nil {
+		t.Fatal("Lock should be held by the first migrater")
+	}
+	if err := first.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	db.Collection("migrations_lock").DeleteMany(ctx, bson.D{})
+}
+
+func TestLockExpired(t *testing.T) {
+	ctx := context.Background()
+	db := connectMongo(t)
+	// lock left by crashed process
+	collection := db.Collection("migrations_lock")
+	_, err := collection.InsertOne(ctx, bson.M{
+		"_id":     mongoLockID,
+		"owner":   "crashed",
+		"expires": time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mgo := NewMongoMigrater()
+	mgo.db = db
+	if err := mgo.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := mgo.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	collection.DeleteMany(ctx, bson.D{})
+}
+
 func TestAddMongoMigrationFile(t *testing.T) {
 	err := AddMongoMigrationFile()
 	if err != nil {
@@ -194,3 +365,56 @@ func TestAddMongoMigrationFileOpenFileError(t *testing.T) {
 		t.Errorf("Unsuccessful clear %s", dir)
 	}
 }
+
+func TestMongoTableAndTrackingDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := connectMongo(t)
+	store := db.Client().Database("migrater_tracking")
+	defer store.Drop(ctx)
+	m := NewMigrater()
+	m.SetMongoDatabase(db)
+	m.SetMongoTrackingDatabase(store)
+	m.SetTable("applied_migrations")
+	m.AddMongoMigration(memoryMigration(1, "1"))
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	count, err := store.Collection("applied_migrations").CountDocuments(ctx, bson.M{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 1 {
+		t.Fatal("Expected", 1, "Got", count)
+	}
+	count, err = db.Collection("migrations").CountDocuments(ctx, bson.M{"timestamp": 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 0 {
+		t.Fatal("Expected", 0, "Got", count)
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestMongoTrackingDatabaseOtherClient(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	other, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m := NewMigrater()
+	m.SetMongoDatabase(client.Database("migrater"))
+	m.SetMongoTrackingDatabase(other.Database("migrater_tracking"))
+	m.SetMongoTransactions(true)
+
+	err = m.mongo.ExecuteTx(context.Background(), memoryMigration(1, "1"), Up, MigrationRecord{Timestamp: 1})
+	if !errors.Is(err, ErrTrackingClient) {
+		t.Fatal("Expected", ErrTrackingClient, "Got", err)
+	}
+}
@@ -0,0 +1,146 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+)
+
+// MySQLMigrater is a Driver which runs sql migrations
+// against mysql or mariadb and keeps track of them
+// in schema_migrations table
+//
+// MySQL commits DDL statements implicitly, so failed
+// migration can leave database half migrated. Because
+// of that every migration is marked as dirty before it
+// is executed and cleared only when it succeeds.
+// Migrater refuses to run while any migration is dirty,
+// until it is resolved with Resolve
+//
+// Connection has to be opened with parseTime=true
+type MySQLMigrater struct {
+	sqlDriver
+}
+
+func NewMySQLMigrater(db *sql.DB) *MySQLMigrater {
+	return &MySQLMigrater{
+		sqlDriver{
+			db:    db,
+			table: sqlTable,
+			createTable: `CREATE TABLE IF NOT EXISTS %s (
+				timestamp BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+				description VARCHAR(255) NOT NULL,
+				migrated DATETIME(6) NOT NULL,
+				batch INT NOT NULL DEFAULT 0,
+				checksum VARCHAR(255) NOT NULL DEFAULT '',
+				dirty BOOLEAN NOT NULL DEFAULT FALSE
+			)`,
+			placeholder: func(n int) string {
+				return "?"
+			},
+			backslashEscapes: true,
+		},
+	}
+}
+
+// mysqlLockName is a name of lock
+// held while migrations are running
+const mysqlLockName = "migrater"
+
+// Lock takes named lock with GET_LOCK,
+// which waits for the lock on its own
+func (my *MySQLMigrater) Lock(ctx context.Context, wait time.Duration) error {
+	return my.lock(ctx, func(conn *sql.Conn) error {
+		var ok sql.NullInt64
+		seconds := int64(math.Ceil(wait.Seconds()))
+		err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", mysqlLockName, seconds).Scan(&ok)
+		if err != nil {
+			return err
+		}
+		if ok.Int64 != 1 {
+			return lockTimeoutError(wait)
+		}
+		return nil
+	})
+}
+
+func (my *MySQLMigrater) Unlock(ctx context.Context) error {
+	return my.unlock(ctx, "SELECT RELEASE_LOCK(?)", mysqlLockName)
+}
+
+func (my *MySQLMigrater) Applied(ctx context.Context) ([]MigrationRecord, error) {
+	if err := my.prepare(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := my.storeDatabase().QueryContext(ctx, my.query("SELECT timestamp, description, migrated, batch, checksum, dirty FROM %s ORDER BY timestamp"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []MigrationRecord{}
+	for rows.Next() {
+		rec := MigrationRecord{}
+		if err := rows.Scan(&rec.Timestamp, &rec.Description, &rec.Migrated, &rec.Batch, &rec.Checksum, &rec.Dirty); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// Record saves applied migration and clears its dirty flag
+func (my *MySQLMigrater) Record(ctx context.Context, rec MigrationRecord) error {
+	if err := my.prepare(ctx); err != nil {
+		return err
+	}
+	_, err := my.storeDatabase().ExecContext(
+		ctx,
+		my.query(`INSERT INTO %s (timestamp, description, migrated, batch, checksum, dirty) VALUES (?, ?, ?, ?, ?, FALSE)
+		ON DUPLICATE KEY UPDATE description = VALUES(description), migrated = VALUES(migrated), batch = VALUES(batch), checksum = VALUES(checksum), dirty = FALSE`),
+		rec.Timestamp, rec.Description, rec.Migrated, rec.Batch, rec.Checksum,
+	)
+	return err
+}
+
+// Execute marks migration as dirty and runs it.
+// When migration fails the dirty flag stays in database
+func (my *MySQLMigrater) Execute(ctx context.Context, mgtn Migration, direction Direction) error {
+	if err := my.markDirty(ctx, mgtn); err != nil {
+		return err
+	}
+	return my.sqlDriver.Execute(ctx, mgtn, direction)
+}
+
+func (my *MySQLMigrater) markDirty(ctx context.Context, mgtn Migration) error {
+	if err := my.prepare(ctx); err != nil {
+		return err
+	}
+	_, err := my.storeDatabase().ExecContext(
+		ctx,
+		my.query(`INSERT INTO %s (timestamp, description, migrated, dirty) VALUES (?, ?, ?, TRUE)
+		ON DUPLICATE KEY UPDATE dirty = TRUE`),
+		mgtn.GetTimestamp(), mgtn.GetDescription(), time.Now(),
+	)
+	return err
+}
+
+// Resolve clears dirty state of migration after
+// database was fixed manually. When applied is true
+// migration is kept as applied, otherwise it is
+// removed and will be run again
+func (my *MySQLMigrater) Resolve(ctx context.Context, timestamp uint64, applied bool) error {
+	if err := my.prepare(ctx); err != nil {
+		return err
+	}
+	if !applied {
+		return my.Remove(ctx, timestamp)
+	}
+	_, err := my.storeDatabase().ExecContext(ctx, my.query("UPDATE %s SET dirty = FALSE WHERE timestamp = ?"), timestamp)
+	return err
+}
+
+func AddMySQLMigrationFile() error {
+	return addMigrationFile(sqlStub, GeneratorConfig{})
+}
@@ -0,0 +1,158 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// connectMySQL skips the test when
+// mysql is not configured with env variables
+func connectMySQL(t *testing.T) *sql.DB {
+	if len(os.Getenv("MYSQL_HOST")) == 0 {
+		t.Skip("MYSQL_HOST env variable is not set")
+	}
+	dsn := fmt.Sprintf(
+		"root:mysql@tcp(%s:%s)/migrater?parseTime=true",
+		os.Getenv("MYSQL_HOST"),
+		os.Getenv("MYSQL_PORT"),
+	)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal("Unable to connect to MySQL")
+	}
+	return db
+}
+
+func TestMySQLRunAndRollback(t *testing.T) {
+	db := connectMySQL(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetMySQLDatabase(db)
+
+	m.AddSQLMigration(SQLMigration{
+		Timestamp:   uint64(time.Now().Unix()),
+		Description: "Create table",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.Exec("CREATE TABLE migrater_test (id INT)")
+			return err
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE migrater_test")
+			return err
+		},
+	})
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 1 || records[0].Dirty {
+		t.Fatal("Expected one clean record, Got", records)
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	records, err = m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 0 {
+		t.Fatal("Expected", 0, "Got", len(records))
+	}
+	db.Exec("DROP TABLE schema_migrations")
+}
+
+func TestMySQLDirtyMigration(t *testing.T) {
+	db := connectMySQL(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetMySQLDatabase(db)
+
+	mig := SQLMigration{
+		Timestamp:   uint64(time.Now().Unix()),
+		Description: "Your description",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			return errors.New("Testing purpose error")
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			return nil
+		},
+	}
+	m.AddSQLMigration(mig)
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 1 || !records[0].Dirty {
+		t.Fatal("Expected one dirty record, Got", records)
+	}
+	// next run refuses to proceed
+	mig.Up = func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	}
+	m.AddSQLMigration(mig)
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	// after resolving migration runs again
+	my := m.driver.(*MySQLMigrater)
+	if err := my.Resolve(context.Background(), mig.Timestamp, false); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	db.Exec("DROP TABLE schema_migrations")
+}
+
+func TestMySQLLock(t *testing.T) {
+	db := connectMySQL(t)
+	defer db.Close()
+	first := NewMySQLMigrater(db)
+	second := NewMySQLMigrater(db)
+
+	if err := first.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Lock(context.Background(), time.Second); err == nil {
+		t.Fatal("Lock should be held by the first migrater")
+	}
+	if err := first.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestAddMySQLMigrationFile(t *testing.T) {
+	err := AddMySQLMigrationFile()
+	if err != nil {
+		t.Error("Error during call AddMySQLMigrationFile")
+	}
+	dir := filepath.Join("app")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		t.Errorf("Dir %s should exist", dir)
+	}
+	// remove testing dir
+	err = os.RemoveAll(dir)
+	if err != nil {
+		t.Errorf("Unsuccessful clear %s", dir)
+	}
+}
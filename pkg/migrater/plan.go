@@ -0,0 +1,150 @@
+package migrater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Plan is an ordered list of migrations
+// which would be applied or reverted
+type Plan struct {
+	Direction  Direction
+	Migrations []Migration
+}
+
+// PlanRun returns migrations which Run would apply.
+// Nothing is executed and saved to database
+func (m *migrater) PlanRun() (*Plan, error) {
+	return m.PlanRunContext(context.Background())
+}
+
+func (m *migrater) PlanRunContext(ctx context.Context) (*Plan, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.planRun(m.migrations, applied), nil
+}
+
+// PlanRollback returns migrations which Rollback
+// called with the same timestamps would revert.
+// Nothing is executed and saved to database
+func (m *migrater) PlanRollback(timestamps ...string) (*Plan, error) {
+	return m.PlanRollbackContext(context.Background(), timestamps...)
+}
+
+func (m *migrater) PlanRollbackContext(ctx context.Context, timestamps ...string) (*Plan, error) {
+	migrations, err := m.reduceMigrations(timestamps...)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.planRollback(migrations, applied), nil
+}
+
+// PlanSteps returns migrations which Steps
+// called with the same n would apply or revert
+func (m *migrater) PlanSteps(n int) (*Plan, error) {
+	return m.PlanStepsContext(context.Background(), n)
+}
+
+func (m *migrater) PlanStepsContext(ctx context.Context, n int) (*Plan, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.planSteps(n, applied), nil
+}
+
+// PlanRollbackLastBatch returns migrations which
+// RollbackLastBatch would revert
+func (m *migrater) PlanRollbackLastBatch() (*Plan, error) {
+	return m.PlanRollbackLastBatchContext(context.Background())
+}
+
+func (m *migrater) PlanRollbackLastBatchContext(ctx context.Context) (*Plan, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.planLastBatch(applied), nil
+}
+
+// planRun returns not applied migrations
+// in ascending timestamp order
+func (m *migrater) planRun(migrations []Migration, applied map[uint64]MigrationRecord) *Plan {
+	plan := &Plan{Direction: Up}
+	for _, migration := range migrations {
+		if _, ok := applied[migration.GetTimestamp()]; !ok {
+			plan.Migrations = append(plan.Migrations, migration)
+		}
+	}
+	return plan
+}
+
+// planRollback returns applied migrations
+// in descending timestamp order
+func (m *migrater) planRollback(migrations []Migration, applied map[uint64]MigrationRecord) *Plan {
+	plan := &Plan{Direction: Down}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[migrations[i].GetTimestamp()]; ok {
+			plan.Migrations = append(plan.Migrations, migrations[i])
+		}
+	}
+	return plan
+}
+
+// planSteps returns n pending migrations or
+// -n applied migrations when n is negative
+func (m *migrater) planSteps(n int, applied map[uint64]MigrationRecord) *Plan {
+	var plan *Plan
+	if n < 0 {
+		n = -n
+		plan = m.planRollback(m.migrations, applied)
+	} else {
+		plan = m.planRun(m.migrations, applied)
+	}
+	if len(plan.Migrations) > n {
+		plan.Migrations = plan.Migrations[:n]
+	}
+	return plan
+}
+
+// planLastBatch returns migrations applied
+// by the latest batch in descending timestamp order.
+// Records saved before batches were introduced have
+// batch 0 and are never planned, because it is unknown
+// which run applied them
+func (m *migrater) planLastBatch(applied map[uint64]MigrationRecord) *Plan {
+	last := lastBatch(applied)
+	plan := &Plan{Direction: Down}
+	if last == 0 {
+		return plan
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		rec, ok := applied[m.migrations[i].GetTimestamp()]
+		if ok && rec.Batch == last {
+			plan.Migrations = append(plan.Migrations, m.migrations[i])
+		}
+	}
+	return plan
+}
+
+// String lists planned migrations one per line
+func (p *Plan) String() string {
+	if len(p.Migrations) == 0 {
+		if p.Direction == Down {
+			return "There is nothing to rollback\n"
+		}
+		return "There is nothing to migrate\n"
+	}
+	var b strings.Builder
+	for _, migration := range p.Migrations {
+		fmt.Fprintf(&b, "%s %d (%s)\n", p.Direction, migration.GetTimestamp(), migration.GetDescription())
+	}
+	return b.String()
+}
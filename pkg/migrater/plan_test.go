@@ -0,0 +1,96 @@
+package migrater
+
+import (
+	"testing"
+)
+
+func TestPlanRun(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{
+		records: []MigrationRecord{{Timestamp: 2}},
+	}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(3, "3"))
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(1, "1"))
+
+	plan, err := m.PlanRun()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if plan.Direction != Up {
+		t.Fatal("Expected", Up, "Got", plan.Direction)
+	}
+	expected := "up 1 (1)\nup 3 (3)\n"
+	if plan.String() != expected {
+		t.Fatal("Expected", expected, "Got", plan.String())
+	}
+	if len(d.calls) > 0 || len(d.records) != 1 {
+		t.Fatal("Plan should not execute nor record migrations")
+	}
+}
+
+func TestPlanRollback(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{
+		records: []MigrationRecord{{Timestamp: 1}, {Timestamp: 2}, {Timestamp: 3}},
+	}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(1, "1"))
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(3, "3"))
+
+	plan, err := m.PlanRollback()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := "down 3 (3)\ndown 2 (2)\ndown 1 (1)\n"
+	if plan.String() != expected {
+		t.Fatal("Expected", expected, "Got", plan.String())
+	}
+
+	plan, err = m.PlanRollback("1", "3")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected = "down 3 (3)\ndown 1 (1)\n"
+	if plan.String() != expected {
+		t.Fatal("Expected", expected, "Got", plan.String())
+	}
+	if len(m.migrations) != 3 {
+		t.Fatal("Planning should not change registered migrations")
+	}
+	if len(d.calls) > 0 || len(d.records) != 3 {
+		t.Fatal("Plan should not execute nor remove migrations")
+	}
+}
+
+func TestPlanRollbackBadTimestamp(t *testing.T) {
+	m := NewMigrater()
+	m.SetDriver(&memoryDriver{})
+	m.AddMigration(memoryMigration(1, "1"))
+
+	if _, err := m.PlanRollback("bad_timestamp"); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestPlanNothing(t *testing.T) {
+	m := NewMigrater()
+	m.SetDriver(&memoryDriver{})
+
+	plan, err := m.PlanRun()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if plan.String() != "There is nothing to migrate\n" {
+		t.Fatal("Unexpected plan", plan.String())
+	}
+	plan, err = m.PlanRollback()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if plan.String() != "There is nothing to rollback\n" {
+		t.Fatal("Unexpected plan", plan.String())
+	}
+}
@@ -0,0 +1,64 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresMigrater is a Driver which runs sql migrations
+// against postgres and keeps track of them
+// in schema_migrations table
+type PostgresMigrater struct {
+	sqlDriver
+}
+
+func NewPostgresMigrater(db *sql.DB) *PostgresMigrater {
+	return &PostgresMigrater{
+		sqlDriver{
+			db:    db,
+			table: sqlTable,
+			createTable: `CREATE TABLE IF NOT EXISTS %s (
+				timestamp BIGINT PRIMARY KEY,
+				description TEXT NOT NULL,
+				migrated TIMESTAMPTZ NOT NULL,
+				batch INTEGER NOT NULL DEFAULT 0,
+				checksum TEXT NOT NULL DEFAULT ''
+			)`,
+			placeholder: func(n int) string {
+				return fmt.Sprintf("$%d", n)
+			},
+		},
+	}
+}
+
+// postgresLockID is a key of advisory lock
+// held while migrations are running
+const postgresLockID int64 = 7316372046
+
+// Lock takes postgres advisory lock
+func (pg *PostgresMigrater) Lock(ctx context.Context, wait time.Duration) error {
+	return pg.lock(ctx, func(conn *sql.Conn) error {
+		return acquireLock(ctx, wait, func() (bool, error) {
+			var ok bool
+			err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", postgresLockID).Scan(&ok)
+			return ok, err
+		})
+	})
+}
+
+func (pg *PostgresMigrater) Unlock(ctx context.Context) error {
+	return pg.unlock(ctx, "SELECT pg_advisory_unlock($1)", postgresLockID)
+}
+
+// ExecuteTx executes migration and its bookkeeping
+// in one transaction, so migration cannot be applied
+// without being recorded
+func (pg *PostgresMigrater) ExecuteTx(ctx context.Context, mgtn Migration, direction Direction, rec MigrationRecord) error {
+	return pg.executeTx(ctx, mgtn, direction, rec)
+}
+
+func AddPostgresMigrationFile() error {
+	return addMigrationFile(sqlStub, GeneratorConfig{})
+}
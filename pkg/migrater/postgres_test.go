@@ -0,0 +1,142 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// connectPostgres skips the test when
+// postgres is not configured with env variables
+func connectPostgres(t *testing.T) *sql.DB {
+	if len(os.Getenv("POSTGRES_HOST")) == 0 {
+		t.Skip("POSTGRES_HOST env variable is not set")
+	}
+	dsn := fmt.Sprintf(
+		"postgres://postgres:postgres@%s:%s/postgres?sslmode=disable",
+		os.Getenv("POSTGRES_HOST"),
+		os.Getenv("POSTGRES_PORT"),
+	)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal("Unable to connect to Postgres")
+	}
+	return db
+}
+
+func TestPostgresRunAndRollback(t *testing.T) {
+	db := connectPostgres(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetPostgresDatabase(db)
+
+	m.AddSQLMigration(SQLMigration{
+		Timestamp:   uint64(time.Now().Unix()),
+		Description: "Create table",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.Exec("CREATE TABLE migrater_test (id INT)")
+			return err
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.Exec("DROP TABLE migrater_test")
+			return err
+		},
+	})
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 1 {
+		t.Fatal("Expected", 1, "Got", len(records))
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	records, err = m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 0 {
+		t.Fatal("Expected", 0, "Got", len(records))
+	}
+	db.Exec("DROP TABLE schema_migrations")
+}
+
+func TestPostgresRunError(t *testing.T) {
+	db := connectPostgres(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetPostgresDatabase(db)
+
+	m.AddSQLMigration(SQLMigration{
+		Timestamp:   uint64(time.Now().Unix()),
+		Description: "Your description",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			return errors.New("Testing purpose error")
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			return nil
+		},
+	})
+	if err := m.Run(); err == nil {
+		t.Error("There should be an error")
+	}
+	db.Exec("DROP TABLE schema_migrations")
+}
+
+func TestPostgresLock(t *testing.T) {
+	db := connectPostgres(t)
+	defer db.Close()
+	first := NewPostgresMigrater(db)
+	second := NewPostgresMigrater(db)
+
+	if err := first.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Lock(context.Background(), time.Second); err == nil {
+		t.Fatal("Lock should be held by the first migrater")
+	}
+	if err := first.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := second.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestPostgresExecuteWrongMigration(t *testing.T) {
+	pg := NewPostgresMigrater(nil)
+	err := pg.Execute(context.Background(), memoryMigration(1, "1"), Up)
+	if err == nil {
+		t.Error("There should be an error")
+	}
+}
+
+func TestAddPostgresMigrationFile(t *testing.T) {
+	err := AddPostgresMigrationFile()
+	if err != nil {
+		t.Error("Error during call AddPostgresMigrationFile")
+	}
+	dir := filepath.Join("app")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		t.Errorf("Dir %s should exist", dir)
+	}
+	// remove testing dir
+	err = os.RemoveAll(dir)
+	if err != nil {
+		t.Errorf("Unsuccessful clear %s", dir)
+	}
+}
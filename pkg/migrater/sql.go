@@ -0,0 +1,304 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+var sqlStub string = `
+package {{ .Package }}
+import (
+	"context"
+	"database/sql"
+
+	"github.com/malekim/migrater/pkg/migrater"
+)
+
+var Migration{{ .Timestamp }} migrater.SQLMigration = migrater.SQLMigration{
+	Timestamp:   {{ .Timestamp }},
+	Description: {{ printf "%q" .Description }},
+	// change checksum whenever Up or Down is changed
+	Checksum: "1",
+	Up: func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	},
+	Down: func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	},
+}
+`
+
+// SQLMigrationFunc gets context passed to RunContext
+// or RollbackContext, which should be used for queries
+type SQLMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+// SQLMigration is a migration for sql drivers.
+// Up and Down are called inside a transaction.
+// Checksum is optional, see Checksummer
+type SQLMigration struct {
+	Timestamp   uint64
+	Description string
+	Checksum    string
+	Up          SQLMigrationFunc
+	Down        SQLMigrationFunc
+}
+
+func (mgtn SQLMigration) GetTimestamp() uint64 {
+	return mgtn.Timestamp
+}
+
+func (mgtn SQLMigration) GetDescription() string {
+	return mgtn.Description
+}
+
+func (mgtn SQLMigration) GetChecksum() string {
+	return mgtn.Checksum
+}
+
+// sqlTable is a default name of table
+// keeping track of migrations
+const sqlTable = "schema_migrations"
+
+// sqlDriver is a common part of sql drivers.
+// It keeps track of migrations in the table, schema_migrations
+// by default, of store database, which is db unless set
+//
+// createTable is dialect specific statement creating
+// the table with %s for its name and placeholder
+// returns n-th query parameter.
+// backslashEscapes is set for dialects, where backslash
+// escapes quotes in strings, so sql files are split again.
+// conn is a connection holding the advisory lock
+type sqlDriver struct {
+	db               *sql.DB
+	store            *sql.DB
+	table            string
+	conn             *sql.Conn
+	prepared         bool
+	createTable      string
+	placeholder      func(n int) string
+	backslashEscapes bool
+}
+
+// SetTable sets name of the table keeping track
+// of migrations. Name is put into queries as it is,
+// so it can be qualified with schema like meta.migrations
+func (d *sqlDriver) SetTable(name string) {
+	if name == "" {
+		name = sqlTable
+	}
+	d.table = name
+	d.prepared = false
+}
+
+// SetTrackingDatabase keeps track of migrations in db
+// instead of the database which is migrated.
+// The lock is taken in db too
+func (d *sqlDriver) SetTrackingDatabase(db *sql.DB) {
+	d.store = db
+	d.prepared = false
+}
+
+func (d *sqlDriver) storeDatabase() *sql.DB {
+	if d.store != nil {
+		return d.store
+	}
+	return d.db
+}
+
+// lock calls acquire on a dedicated connection,
+// because advisory locks belong to the session
+// which acquired them
+func (d *sqlDriver) lock(ctx context.Context, acquire func(conn *sql.Conn) error) error {
+	conn, err := d.storeDatabase().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if err := acquire(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	d.conn = conn
+	return nil
+}
+
+// unlock executes release statement on the connection
+// holding the lock and gives the connection back to pool
+func (d *sqlDriver) unlock(ctx context.Context, release string, args ...interface{}) error {
+	if d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, release, args...)
+	d.conn.Close()
+	d.conn = nil
+	return err
+}
+
+// query replaces ? with dialect specific placeholders
+// and %s with name of the table
+func (d *sqlDriver) query(q string) string {
+	q = fmt.Sprintf(q, d.table)
+	parts := strings.Split(q, "?")
+	var b strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteString(d.placeholder(i))
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// prepare creates migrations table if it does not exist
+func (d *sqlDriver) prepare(ctx context.Context) error {
+	if d.prepared {
+		return nil
+	}
+	_, err := d.storeDatabase().ExecContext(ctx, fmt.Sprintf(d.createTable, d.table))
+	if err != nil {
+		return err
+	}
+	d.prepared = true
+	return nil
+}
+
+func (d *sqlDriver) Applied(ctx context.Context) ([]MigrationRecord, error) {
+	if err := d.prepare(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := d.storeDatabase().QueryContext(ctx, d.query("SELECT timestamp, description, migrated, batch, checksum FROM %s ORDER BY timestamp"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []MigrationRecord{}
+	for rows.Next() {
+		rec := MigrationRecord{}
+		if err := rows.Scan(&rec.Timestamp, &rec.Description, &rec.Migrated, &rec.Batch, &rec.Checksum); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+func (d *sqlDriver) Record(ctx context.Context, rec MigrationRecord) error {
+	if err := d.prepare(ctx); err != nil {
+		return err
+	}
+	_, err := d.storeDatabase().ExecContext(
+		ctx,
+		d.query("INSERT INTO %s (timestamp, description, migrated, batch, checksum) VALUES (?, ?, ?, ?, ?)"),
+		rec.Timestamp, rec.Description, rec.Migrated, rec.Batch, rec.Checksum,
+	)
+	return err
+}
+
+func (d *sqlDriver) Remove(ctx context.Context, timestamp uint64) error {
+	if err := d.prepare(ctx); err != nil {
+		return err
+	}
+	_, err := d.storeDatabase().ExecContext(ctx, d.query("DELETE FROM %s WHERE timestamp = ?"), timestamp)
+	return err
+}
+
+// Execute calls Up or Down of sql migration
+// inside a transaction and commits it on success.
+// Sql file migrations may opt out of the transaction
+func (d *sqlDriver) Execute(ctx context.Context, mgtn Migration, direction Direction) error {
+	switch migration := mgtn.(type) {
+	case SQLMigration:
+		fn := migration.Up
+		if direction == Down {
+			fn = migration.Down
+		}
+		tx, err := d.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(ctx, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	case SQLFileMigration:
+		if direction == Down {
+			return d.script(migration.Down).execute(ctx, d.db)
+		}
+		return d.script(migration.Up).execute(ctx, d.db)
+	}
+	return fmt.Errorf("SQL driver cannot execute migration of type %T", mgtn)
+}
+
+// executeTx executes migration and records it, or removes
+// its record, in one transaction. Bookkeeping is done separately
+// when migrations are tracked in other database or sql file
+// opts out of the transaction
+func (d *sqlDriver) executeTx(ctx context.Context, mgtn Migration, direction Direction, rec MigrationRecord) error {
+	transactional := d.store == nil || d.store == d.db
+	var script SQLScript
+	if migration, ok := mgtn.(SQLFileMigration); ok {
+		script = migration.Up
+		if direction == Down {
+			script = migration.Down
+		}
+		script = d.script(script)
+		transactional = transactional && !script.NoTransaction
+	}
+	if !transactional {
+		if err := d.Execute(ctx, mgtn, direction); err != nil {
+			return err
+		}
+		if direction == Down {
+			return d.Remove(ctx, rec.Timestamp)
+		}
+		return d.Record(ctx, rec)
+	}
+	// table is created outside, so rolled back
+	// transaction does not drop it
+	if err := d.prepare(ctx); err != nil {
+		return err
+	}
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	switch migration := mgtn.(type) {
+	case SQLMigration:
+		fn := migration.Up
+		if direction == Down {
+			fn = migration.Down
+		}
+		err = fn(ctx, tx)
+	case SQLFileMigration:
+		err = script.executeTx(ctx, tx)
+	default:
+		err = fmt.Errorf("SQL driver cannot execute migration of type %T", mgtn)
+	}
+	if err == nil && direction == Down {
+		_, err = tx.ExecContext(ctx, d.query("DELETE FROM %s WHERE timestamp = ?"), rec.Timestamp)
+	} else if err == nil {
+		_, err = tx.ExecContext(
+			ctx,
+			d.query("INSERT INTO %s (timestamp, description, migrated, batch, checksum) VALUES (?, ?, ?, ?, ?)"),
+			rec.Timestamp, rec.Description, rec.Migrated, rec.Batch, rec.Checksum,
+		)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// script splits loaded sql file again when
+// the dialect uses backslash escapes
+func (d *sqlDriver) script(script SQLScript) SQLScript {
+	if !d.backslashEscapes || script.source == "" {
+		return script
+	}
+	return parseSQLScript(script.source, true)
+}
@@ -0,0 +1,191 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// sqlAnnotation starts comments which
+// tell how to run the sql file
+const sqlAnnotation = "-- +migrate"
+
+// SQLScript is a content of .up.sql or .down.sql file.
+// Statements are executed in one transaction
+// unless NoTransaction is set
+type SQLScript struct {
+	Statements    []string
+	NoTransaction bool
+	// source is the parsed file, kept to split
+	// it again for dialects with backslash escapes
+	source string
+}
+
+// SQLFileMigration is a migration for sql drivers
+// written as plain .up.sql and .down.sql files
+type SQLFileMigration struct {
+	Timestamp   uint64
+	Description string
+	Checksum    string
+	Up          SQLScript
+	Down        SQLScript
+}
+
+func (mgtn SQLFileMigration) GetTimestamp() uint64 {
+	return mgtn.Timestamp
+}
+
+func (mgtn SQLFileMigration) GetDescription() string {
+	return mgtn.Description
+}
+
+func (mgtn SQLFileMigration) GetChecksum() string {
+	return mgtn.Checksum
+}
+
+// execute runs statements of the script in a transaction,
+// or one after another when NoTransaction is set
+func (script SQLScript) execute(ctx context.Context, db *sql.DB) error {
+	if script.NoTransaction {
+		for _, statement := range script.Statements {
+			if _, err := db.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := script.executeTx(ctx, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// executeTx runs statements of the script in passed transaction
+func (script SQLScript) executeTx(ctx context.Context, tx *sql.Tx) error {
+	for _, statement := range script.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSQLScript splits sql file into statements on
+// semicolons which are not inside a quoted string,
+// a dollar quoted string or a comment. Annotations are:
+//
+//	-- +migrate NoTransaction
+//	-- +migrate StatementBegin
+//	-- +migrate StatementEnd
+//
+// Statements between StatementBegin and StatementEnd
+// are not split, which is useful for function bodies.
+// With backslashEscapes backslash escapes the next character
+// inside ' and " strings, like in mysql by default
+func parseSQLScript(data string, backslashEscapes bool) SQLScript {
+	script := SQLScript{source: data}
+	var statement strings.Builder
+	// code is false while statement has
+	// only comments and whitespace
+	code := false
+	flush := func() {
+		if s := strings.TrimSpace(statement.String()); s != "" && code {
+			script.Statements = append(script.Statements, s)
+		}
+		statement.Reset()
+		code = false
+	}
+
+	// quote is a sequence closing currently open
+	// string, dollar quoted string or comment
+	quote := ""
+	block := false
+	lineStart := true
+	for i := 0; i < len(data); i++ {
+		if lineStart && quote == "" {
+			line := data[i:]
+			end := strings.IndexByte(line, '\n')
+			if end < 0 {
+				end = len(line)
+			}
+			trimmed := strings.TrimSpace(line[:end])
+			if strings.HasPrefix(trimmed, sqlAnnotation) {
+				switch strings.TrimSpace(strings.TrimPrefix(trimmed, sqlAnnotation)) {
+				case "NoTransaction":
+					script.NoTransaction = true
+				case "StatementBegin":
+					flush()
+					block = true
+				case "StatementEnd":
+					flush()
+					block = false
+				}
+				i += end
+				continue
+			}
+		}
+		c := data[i]
+		lineStart = c == '\n'
+		rest := data[i:]
+		switch {
+		case block:
+		case backslashEscapes && c == '\\' && (quote == "'" || quote == "\"") && i+1 < len(data):
+			statement.WriteString(data[i : i+2])
+			i++
+			continue
+		case quote != "":
+			if strings.HasPrefix(rest, quote) {
+				statement.WriteString(quote)
+				i += len(quote) - 1
+				lineStart = quote == "\n"
+				quote = ""
+				continue
+			}
+		case strings.HasPrefix(rest, "--"):
+			quote = "\n"
+		case strings.HasPrefix(rest, "/*"):
+			quote = "*/"
+		case c == '\'' || c == '"' || c == '`':
+			quote = string(c)
+		case c == '$':
+			if tag := dollarTag(rest); tag != "" {
+				code = true
+				statement.WriteString(tag)
+				i += len(tag) - 1
+				quote = tag
+				continue
+			}
+		case c == ';':
+			statement.WriteByte(c)
+			flush()
+			continue
+		}
+		if quote != "\n" && quote != "*/" && !unicode.IsSpace(rune(c)) {
+			code = true
+		}
+		statement.WriteByte(c)
+	}
+	flush()
+	return script
+}
+
+// dollarTag returns postgres dollar quote tag
+// like $$ or $body$ at the beginning of s
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 1 && c >= '0' && c <= '9')) {
+			return ""
+		}
+	}
+	return ""
+}
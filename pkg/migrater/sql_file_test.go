@@ -0,0 +1,197 @@
+package migrater
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/malekim/migrater/internal/sqlitetest"
+)
+
+var sqlUpFile = `-- create users
+CREATE TABLE users (id INTEGER, name TEXT DEFAULT 'a;b');
+/* comment; with semicolon */
+INSERT INTO users (id, name) VALUES (1, 'it''s; fine');
+`
+
+var sqlDownFile = `DROP TABLE users;`
+
+func TestParseSQLScript(t *testing.T) {
+	script := parseSQLScript(sqlUpFile, false)
+	expected := []string{
+		"-- create users\nCREATE TABLE users (id INTEGER, name TEXT DEFAULT 'a;b');",
+		"/* comment; with semicolon */\nINSERT INTO users (id, name) VALUES (1, 'it''s; fine');",
+	}
+	if !reflect.DeepEqual(script.Statements, expected) {
+		t.Fatal("Expected", expected, "Got", script.Statements)
+	}
+	if script.NoTransaction {
+		t.Fatal("Script should run in transaction")
+	}
+}
+
+func TestParseSQLScriptDollarQuote(t *testing.T) {
+	script := parseSQLScript(`CREATE FUNCTION f() RETURNS void AS $body$ BEGIN PERFORM 1; END; $body$ LANGUAGE plpgsql;
+SELECT $$a;b$$, $1;`, false)
+	expected := []string{
+		"CREATE FUNCTION f() RETURNS void AS $body$ BEGIN PERFORM 1; END; $body$ LANGUAGE plpgsql;",
+		"SELECT $$a;b$$, $1;",
+	}
+	if !reflect.DeepEqual(script.Statements, expected) {
+		t.Fatal("Expected", expected, "Got", script.Statements)
+	}
+}
+
+func TestParseSQLScriptTrailingComment(t *testing.T) {
+	script := parseSQLScript("SELECT $1;-- trailing\n/* block */\n", false)
+	expected := []string{"SELECT $1;"}
+	if !reflect.DeepEqual(script.Statements, expected) {
+		t.Fatal("Expected", expected, "Got", script.Statements)
+	}
+}
+
+func TestParseSQLScriptBackslashEscapes(t *testing.T) {
+	data := "INSERT INTO users (name) VALUES ('it\\'s; fine', \"a\\\";b\");\nCREATE TABLE x (id int);"
+	script := parseSQLScript(data, true)
+	expected := []string{
+		"INSERT INTO users (name) VALUES ('it\\'s; fine', \"a\\\";b\");",
+		"CREATE TABLE x (id int);",
+	}
+	if !reflect.DeepEqual(script.Statements, expected) {
+		t.Fatal("Expected", expected, "Got", script.Statements)
+	}
+	// mysql driver splits loaded file again
+	loaded := parseSQLScript(data, false)
+	if got := NewMySQLMigrater(nil).script(loaded).Statements; !reflect.DeepEqual(got, expected) {
+		t.Fatal("Expected", expected, "Got", got)
+	}
+	// backslash is not an escape in standard sql
+	script = parseSQLScript(`INSERT INTO paths VALUES ('C:\'); SELECT 1;`, false)
+	if len(script.Statements) != 2 {
+		t.Fatal("Expected", 2, "Got", script.Statements)
+	}
+}
+
+func TestParseSQLScriptAnnotations(t *testing.T) {
+	script := parseSQLScript(`-- +migrate NoTransaction
+CREATE INDEX a ON users (id);
+-- +migrate StatementBegin
+CREATE TRIGGER t AFTER INSERT ON users BEGIN
+  UPDATE users SET name = 'x';
+END;
+-- +migrate StatementEnd
+SELECT 1`, false)
+	expected := []string{
+		"CREATE INDEX a ON users (id);",
+		"CREATE TRIGGER t AFTER INSERT ON users BEGIN\n  UPDATE users SET name = 'x';\nEND;",
+		"SELECT 1",
+	}
+	if !reflect.DeepEqual(script.Statements, expected) {
+		t.Fatal("Expected", expected, "Got", script.Statements)
+	}
+	if !script.NoTransaction {
+		t.Fatal("Script should not run in transaction")
+	}
+}
+
+func TestLoadMigrationsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/2_add_users.up.sql":   {Data: []byte(sqlUpFile)},
+		"migrations/2_add_users.down.sql": {Data: []byte(sqlDownFile)},
+		"migrations/1_first.json":         {Data: []byte(mongoJSONMigration)},
+		"migrations/3_no_down.up.sql":     {Data: []byte("SELECT 1;")},
+		"migrations/embed.go":             {Data: []byte("package migrations")},
+	}
+	migrations, err := LoadMigrationsFS(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(migrations) != 3 {
+		t.Fatal("Expected", 3, "Got", len(migrations))
+	}
+	migration, ok := migrations[1].(SQLFileMigration)
+	if !ok {
+		t.Fatal("Unexpected migration", migrations[1])
+	}
+	if migration.Description != "add users" {
+		t.Fatal("Expected", "add users", "Got", migration.Description)
+	}
+	if len(migration.Up.Statements) != 2 || len(migration.Down.Statements) != 1 {
+		t.Fatal("Unexpected statements", migration.Up, migration.Down)
+	}
+	if migration.Checksum == "" {
+		t.Fatal("Checksum should be set")
+	}
+	if len(migrations[2].(SQLFileMigration).Down.Statements) != 0 {
+		t.Fatal("Down of migration without down file should be empty")
+	}
+}
+
+func TestLoadMigrationsFSErrors(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"no direction": {"1_users.sql": {Data: []byte("SELECT 1;")}},
+		"no up file":   {"1_users.down.sql": {Data: []byte("SELECT 1;")}},
+		"two up files": {
+			"1_users.up.sql":  {Data: []byte("SELECT 1;")},
+			"1_orders.up.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+	for name, fsys := range cases {
+		if _, err := LoadMigrationsFS(fsys, "."); err == nil {
+			t.Fatal("There should be an error for", name)
+		}
+	}
+}
+
+func TestSQLiteRunSQLFileMigration(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	fsys := fstest.MapFS{
+		"1_add_users.up.sql":   {Data: []byte(sqlUpFile)},
+		"1_add_users.down.sql": {Data: []byte(sqlDownFile)},
+		"2_add_index.up.sql":   {Data: []byte("-- +migrate NoTransaction\nCREATE INDEX users_id ON users (id);")},
+		"2_add_index.down.sql": {Data: []byte("DROP INDEX users_id;")},
+	}
+	migrations, err := LoadMigrationsFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+	for _, migration := range migrations {
+		m.AddMigration(migration)
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	var name string
+	if err := db.QueryRow("SELECT name FROM users WHERE id = 1").Scan(&name); err != nil {
+		t.Fatal(err.Error())
+	}
+	if name != "it's; fine" {
+		t.Fatal("Expected", "it's; fine", "Got", name)
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if countTable(t, db, "users") != 0 {
+		t.Fatal("Table users should be dropped")
+	}
+}
+
+func TestSQLiteSQLFileMigrationRollsBack(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+	m.AddMigration(SQLFileMigration{
+		Timestamp: 1,
+		Up:        parseSQLScript("CREATE TABLE users (id INTEGER);\nINSERT INTO missing VALUES (1);", false),
+	})
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if countTable(t, db, "users") != 0 {
+		t.Fatal("Table users should be rolled back")
+	}
+}
@@ -0,0 +1,127 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// sqliteLockTTL is how long the lock row is valid
+// unless it is extended by heartbeat
+const sqliteLockTTL = 30 * time.Second
+
+// SQLiteMigrater is a Driver which runs sql migrations
+// against sqlite and keeps track of them
+// in schema_migrations table
+type SQLiteMigrater struct {
+	sqlDriver
+	lockOwner     string
+	stopHeartbeat chan struct{}
+	heartbeatDone chan struct{}
+}
+
+func NewSQLiteMigrater(db *sql.DB) *SQLiteMigrater {
+	return &SQLiteMigrater{
+		sqlDriver: sqlDriver{
+			db:    db,
+			table: sqlTable,
+			createTable: `CREATE TABLE IF NOT EXISTS %s (
+				timestamp INTEGER PRIMARY KEY,
+				description TEXT NOT NULL,
+				migrated DATETIME NOT NULL,
+				batch INTEGER NOT NULL DEFAULT 0,
+				checksum TEXT NOT NULL DEFAULT ''
+			)`,
+			placeholder: func(n int) string {
+				return "?"
+			},
+		},
+	}
+}
+
+// Lock inserts lock row to schema_migrations_lock table,
+// because sqlite has no advisory locks. Like mongo lock
+// the row expires after sqliteLockTTL unless it is extended
+// by heartbeat, so crashed process does not keep it forever
+func (lite *SQLiteMigrater) Lock(ctx context.Context, wait time.Duration) error {
+	db := lite.storeDatabase()
+	_, err := db.ExecContext(ctx, lite.query(`CREATE TABLE IF NOT EXISTS %s_lock (
+		id INTEGER PRIMARY KEY,
+		owner TEXT NOT NULL,
+		expires INTEGER NOT NULL
+	)`))
+	if err != nil {
+		return err
+	}
+	owner := lockOwner()
+	err = acquireLock(ctx, wait, func() (bool, error) {
+		now := time.Now()
+		// take over expired lock or insert a new one
+		// in a single statement, so it is atomic
+		res, err := db.ExecContext(
+			ctx,
+			lite.query(`INSERT INTO %s_lock (id, owner, expires) VALUES (1, ?, ?)
+				ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, expires = excluded.expires
+				WHERE expires < ?`),
+			owner, now.Add(sqliteLockTTL).UnixNano(), now.UnixNano(),
+		)
+		if err != nil {
+			return false, err
+		}
+		n, err := res.RowsAffected()
+		return n == 1, err
+	})
+	if err != nil {
+		return err
+	}
+	lite.lockOwner = owner
+	lite.stopHeartbeat = make(chan struct{})
+	lite.heartbeatDone = make(chan struct{})
+	go lite.heartbeat(owner, lite.stopHeartbeat, lite.heartbeatDone)
+	return nil
+}
+
+// heartbeat extends the lock until stop is closed
+func (lite *SQLiteMigrater) heartbeat(owner string, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(sqliteLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			lite.storeDatabase().ExecContext(
+				context.Background(),
+				lite.query("UPDATE %s_lock SET expires = ? WHERE id = 1 AND owner = ?"),
+				time.Now().Add(sqliteLockTTL).UnixNano(), owner,
+			)
+		}
+	}
+}
+
+func (lite *SQLiteMigrater) Unlock(ctx context.Context) error {
+	if lite.stopHeartbeat == nil {
+		return nil
+	}
+	close(lite.stopHeartbeat)
+	<-lite.heartbeatDone
+	lite.stopHeartbeat = nil
+	_, err := lite.storeDatabase().ExecContext(
+		ctx,
+		lite.query("DELETE FROM %s_lock WHERE id = 1 AND owner = ?"),
+		lite.lockOwner,
+	)
+	return err
+}
+
+// ExecuteTx executes migration and its bookkeeping
+// in one transaction, so migration cannot be applied
+// without being recorded
+func (lite *SQLiteMigrater) ExecuteTx(ctx context.Context, mgtn Migration, direction Direction, rec MigrationRecord) error {
+	return lite.executeTx(ctx, mgtn, direction, rec)
+}
+
+func AddSQLiteMigrationFile() error {
+	return addMigrationFile(sqlStub, GeneratorConfig{})
+}
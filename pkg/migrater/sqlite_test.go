@@ -0,0 +1,481 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/malekim/migrater/internal/sqlitetest"
+)
+
+// sqliteMigration creates table named by timestamp
+// and appends information about calls to passed slice
+func sqliteMigration(timestamp uint64, calls *[]string) SQLMigration {
+	st := strconv.FormatUint(timestamp, 10)
+	return SQLMigration{
+		Timestamp:   timestamp,
+		Description: "Migration " + st,
+		Checksum:    "v" + st,
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			*calls = append(*calls, "up"+st)
+			_, err := tx.Exec("CREATE TABLE t" + st + " (id INTEGER)")
+			return err
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			*calls = append(*calls, "down"+st)
+			_, err := tx.Exec("DROP TABLE t" + st)
+			return err
+		},
+	}
+}
+
+func countTable(t *testing.T, db *sql.DB, name string) int {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return count
+}
+
+func TestSQLiteRunOrder(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	for _, ts := range []uint64{3, 1, 2} {
+		m.AddSQLMigration(sqliteMigration(ts, &calls))
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"up1", "up2", "up3"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatal("Expected", expected, "Got", calls)
+	}
+	if m.counter != 3 {
+		t.Fatal("Counter should be set to", 3, "Got", m.counter)
+	}
+	// second run has nothing to do
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(calls) != 3 {
+		t.Fatal("Migrations should not be called twice, Got", calls)
+	}
+}
+
+func TestSQLiteBookkeeping(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	m.AddSQLMigration(sqliteMigration(1, &calls))
+	m.AddSQLMigration(sqliteMigration(2, &calls))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 2 {
+		t.Fatal("Expected", 2, "Got", len(records))
+	}
+	for i, rec := range records {
+		ts := uint64(i + 1)
+		if rec.Timestamp != ts {
+			t.Fatal("Expected", ts, "Got", rec.Timestamp)
+		}
+		if rec.Description != "Migration "+strconv.FormatUint(ts, 10) {
+			t.Fatal("Unexpected description", rec.Description)
+		}
+		if rec.Migrated.IsZero() {
+			t.Fatal("Migrated time should be set")
+		}
+		if rec.Batch != 1 {
+			t.Fatal("Expected", 1, "Got", rec.Batch)
+		}
+		if rec.Checksum != "v"+strconv.FormatUint(ts, 10) {
+			t.Fatal("Unexpected checksum", rec.Checksum)
+		}
+	}
+}
+
+func TestSQLiteRollback(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	for _, ts := range []uint64{1, 2, 3} {
+		m.AddSQLMigration(sqliteMigration(ts, &calls))
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	calls = calls[:0]
+	m.counter = 0
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"down3", "down2", "down1"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatal("Expected", expected, "Got", calls)
+	}
+	if m.counter != 3 {
+		t.Fatal("Rollback counter should be set to", 3, "Got", m.counter)
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 0 {
+		t.Fatal("Expected", 0, "Got", len(records))
+	}
+}
+
+func TestSQLiteRollbackWithReduce(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	m.AddSQLMigration(sqliteMigration(1, &calls))
+	m.AddSQLMigration(sqliteMigration(2, &calls))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.Rollback("1"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if countTable(t, db, "t1") != 0 {
+		t.Fatal("Table t1 should be dropped")
+	}
+	if countTable(t, db, "t2") != 1 {
+		t.Fatal("Table t2 should exist")
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 1 || records[0].Timestamp != 2 {
+		t.Fatal("Only migration 2 should stay applied, Got", records)
+	}
+}
+
+func TestSQLiteRunError(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	mig := sqliteMigration(1, &calls)
+	up := mig.Up
+	mig.Up = func(ctx context.Context, tx *sql.Tx) error {
+		if err := up(ctx, tx); err != nil {
+			return err
+		}
+		return errors.New("Testing purpose error")
+	}
+	m.AddSQLMigration(mig)
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	// transaction should be rolled back
+	if countTable(t, db, "t1") != 0 {
+		t.Fatal("Table t1 should not exist")
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 0 {
+		t.Fatal("Expected", 0, "Got", len(records))
+	}
+}
+
+func TestSQLiteRecordInTransaction(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	mig := sqliteMigration(1, &calls)
+	up := mig.Up
+	mig.Up = func(ctx context.Context, tx *sql.Tx) error {
+		if err := up(ctx, tx); err != nil {
+			return err
+		}
+		// bookkeeping fails on duplicate timestamp
+		_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (timestamp, description, migrated) VALUES (1, '', CURRENT_TIMESTAMP)")
+		return err
+	}
+	m.AddSQLMigration(mig)
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	// migration is rolled back together with its record
+	if countTable(t, db, "t1") != 0 {
+		t.Fatal("Table t1 should not exist")
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 0 {
+		t.Fatal("Expected", 0, "Got", len(records))
+	}
+}
+
+func TestSQLiteRollbackError(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	mig := sqliteMigration(1, &calls)
+	mig.Down = func(ctx context.Context, tx *sql.Tx) error {
+		return errors.New("Testing purpose error")
+	}
+	m.AddSQLMigration(mig)
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := m.Rollback(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 1 {
+		t.Fatal("Failed rollback should keep the record, Got", records)
+	}
+}
+
+func TestSQLiteRunContextCancelled(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	m.AddSQLMigration(sqliteMigration(1, &calls))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.RunContext(ctx); err != context.Canceled {
+		t.Fatal("Expected", context.Canceled, "Got", err)
+	}
+	if len(calls) > 0 {
+		t.Fatal("Nothing should be executed, Got", calls)
+	}
+}
+
+func TestSQLiteRunContextPassedToMigration(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	var got interface{}
+	m.AddSQLMigration(SQLMigration{
+		Timestamp:   1,
+		Description: "Your description",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			got = ctx.Value(key{})
+			return nil
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			got = ctx.Value(key{})
+			return nil
+		},
+	})
+	if err := m.RunContext(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got != "value" {
+		t.Fatal("Migration should get context passed to RunContext")
+	}
+	got = nil
+	if err := m.RollbackContext(ctx); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got != "value" {
+		t.Fatal("Migration should get context passed to RollbackContext")
+	}
+}
+
+func TestSQLiteMigrationTimeout(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+	m.SetMigrationTimeout(10 * time.Millisecond)
+
+	m.AddSQLMigration(SQLMigration{
+		Timestamp:   1,
+		Description: "Your description",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Down: func(ctx context.Context, tx *sql.Tx) error {
+			return nil
+		},
+	})
+	if err := m.Run(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected", context.DeadlineExceeded, "Got", err)
+	}
+	records, err := m.driver.Applied(context.Background())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(records) != 0 {
+		t.Fatal("Expected", 0, "Got", len(records))
+	}
+}
+
+func TestSQLiteLock(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	lite := NewSQLiteMigrater(db)
+	if err := lite.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	// other process waits for the lock
+	other := NewSQLiteMigrater(db)
+	if err := other.Lock(context.Background(), 0); !errors.Is(err, ErrLocked) {
+		t.Fatal("Expected", ErrLocked, "Got", err)
+	}
+	if err := lite.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := other.Lock(context.Background(), 0); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := other.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestSQLiteLockExpired(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	lite := NewSQLiteMigrater(db)
+	if err := lite.Lock(context.Background(), time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer lite.Unlock(context.Background())
+	// lock of crashed process
+	_, err := db.Exec("UPDATE schema_migrations_lock SET expires = ?", time.Now().Add(-time.Second).UnixNano())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	other := NewSQLiteMigrater(db)
+	if err := other.Lock(context.Background(), 0); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := other.Unlock(context.Background()); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestSQLiteExecuteWrongMigration(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	lite := NewSQLiteMigrater(db)
+	err := lite.Execute(context.Background(), memoryMigration(1, "1"), Up)
+	if err == nil {
+		t.Error("There should be an error")
+	}
+}
+
+func TestAddSQLiteMigrationFile(t *testing.T) {
+	err := AddSQLiteMigrationFile()
+	if err != nil {
+		t.Error("Error during call AddSQLiteMigrationFile")
+	}
+	dir := filepath.Join("app")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		t.Errorf("Dir %s should exist", dir)
+	}
+	// remove testing dir
+	err = os.RemoveAll(dir)
+	if err != nil {
+		t.Errorf("Unsuccessful clear %s", dir)
+	}
+}
+
+func TestSQLiteTable(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetTable("applied_migrations")
+	m.SetSQLiteDatabase(db)
+
+	calls := []string{}
+	m.AddSQLMigration(sqliteMigration(1, &calls))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if countTable(t, db, "applied_migrations") != 1 {
+		t.Fatal("Table applied_migrations should be created")
+	}
+	if countTable(t, db, "schema_migrations") != 0 {
+		t.Fatal("Table schema_migrations should not be created")
+	}
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(calls) != 2 {
+		t.Fatal("Expected", 2, "Got", len(calls))
+	}
+}
+
+func TestSQLiteTrackingDatabase(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	store := sqlitetest.Open(t)
+	defer store.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+	m.SetSQLTrackingDatabase(store)
+	m.SetTable("tracking")
+
+	calls := []string{}
+	m.AddSQLMigration(sqliteMigration(1, &calls))
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if countTable(t, db, "t1") != 1 {
+		t.Fatal("Migration should be executed in migrated database")
+	}
+	if countTable(t, db, "tracking") != 0 {
+		t.Fatal("Migrated database should not keep track of migrations")
+	}
+	var count int
+	if err := store.QueryRow("SELECT COUNT(*) FROM tracking").Scan(&count); err != nil {
+		t.Fatal(err.Error())
+	}
+	if count != 1 {
+		t.Fatal("Expected", 1, "Got", count)
+	}
+}
@@ -0,0 +1,108 @@
+package migrater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// MigrationStatus describes state of single migration.
+//
+// Registered is false for migrations which are
+// applied, but were not added to migrater
+type MigrationStatus struct {
+	Timestamp   uint64     `json:"timestamp"`
+	Description string     `json:"description"`
+	Applied     bool       `json:"applied"`
+	Migrated    *time.Time `json:"migrated,omitempty"`
+	Batch       int        `json:"batch,omitempty"`
+	Registered  bool       `json:"registered"`
+	Dirty       bool       `json:"dirty"`
+}
+
+// Status returns state of registered and applied
+// migrations sorted by timestamp
+func (m *migrater) Status() ([]MigrationStatus, error) {
+	return m.StatusContext(context.Background())
+}
+
+func (m *migrater) StatusContext(ctx context.Context) ([]MigrationStatus, error) {
+	records, err := m.driver.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[uint64]*MigrationStatus)
+	for _, migration := range m.migrations {
+		statuses[migration.GetTimestamp()] = &MigrationStatus{
+			Timestamp:   migration.GetTimestamp(),
+			Description: migration.GetDescription(),
+			Registered:  true,
+		}
+	}
+	for _, rec := range records {
+		st, ok := statuses[rec.Timestamp]
+		if !ok {
+			st = &MigrationStatus{
+				Timestamp:   rec.Timestamp,
+				Description: rec.Description,
+			}
+			statuses[rec.Timestamp] = st
+		}
+		migrated := rec.Migrated
+		st.Applied = true
+		st.Migrated = &migrated
+		st.Batch = rec.Batch
+		st.Dirty = rec.Dirty
+	}
+
+	result := make([]MigrationStatus, 0, len(statuses))
+	for _, st := range statuses {
+		result = append(result, *st)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result, nil
+}
+
+// WriteStatus renders statuses to w as a "table" or "json"
+func WriteStatus(w io.Writer, statuses []MigrationStatus, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+	case "table", "":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIMESTAMP\tDESCRIPTION\tSTATUS\tMIGRATED\tBATCH")
+		for _, st := range statuses {
+			migrated, batch := "-", "-"
+			if st.Migrated != nil {
+				migrated = st.Migrated.Format(time.RFC3339)
+				batch = strconv.Itoa(st.Batch)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", st.Timestamp, st.Description, st.state(), migrated, batch)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("Unknown status format `%s`, use table or json", format)
+	}
+}
+
+func (st MigrationStatus) state() string {
+	switch {
+	case st.Dirty:
+		return "dirty"
+	case st.Applied && !st.Registered:
+		return "applied, not registered"
+	case st.Applied:
+		return "applied"
+	default:
+		return "pending"
+	}
+}
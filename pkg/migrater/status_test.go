@@ -0,0 +1,89 @@
+package migrater
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	m := NewMigrater()
+	migrated := time.Now()
+	d := &memoryDriver{
+		records: []MigrationRecord{
+			{Timestamp: 1, Description: "1", Migrated: migrated, Batch: 1},
+			{Timestamp: 4, Description: "removed", Migrated: migrated},
+		},
+	}
+	m.SetDriver(d)
+	m.AddMigration(memoryMigration(2, "2"))
+	m.AddMigration(memoryMigration(1, "1"))
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(statuses) != 3 {
+		t.Fatal("Expected", 3, "Got", len(statuses))
+	}
+	first, second, third := statuses[0], statuses[1], statuses[2]
+	if first.Timestamp != 1 || !first.Applied || !first.Registered || !first.Migrated.Equal(migrated) || first.Batch != 1 {
+		t.Fatal("Migration 1 should be applied and registered, Got", first)
+	}
+	if second.Timestamp != 2 || second.Applied || !second.Registered || second.Migrated != nil {
+		t.Fatal("Migration 2 should be pending, Got", second)
+	}
+	if third.Timestamp != 4 || !third.Applied || third.Registered {
+		t.Fatal("Migration 4 should be applied and not registered, Got", third)
+	}
+}
+
+func TestWriteStatusTable(t *testing.T) {
+	migrated := time.Date(2020, 6, 13, 22, 0, 0, 0, time.UTC)
+	statuses := []MigrationStatus{
+		{Timestamp: 1, Description: "first", Applied: true, Registered: true, Migrated: &migrated},
+		{Timestamp: 2, Description: "second", Registered: true},
+		{Timestamp: 3, Description: "third", Applied: true, Migrated: &migrated},
+		{Timestamp: 4, Description: "fourth", Applied: true, Dirty: true, Registered: true, Migrated: &migrated},
+	}
+	var buf bytes.Buffer
+	if err := WriteStatus(&buf, statuses, "table"); err != nil {
+		t.Fatal(err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatal("Expected", 5, "lines, Got", lines)
+	}
+	expected := []string{"2020-06-13T22:00:00Z", "pending", "applied, not registered", "dirty"}
+	for i, e := range expected {
+		if !strings.Contains(lines[i+1], e) {
+			t.Fatal("Line", lines[i+1], "should contain", e)
+		}
+	}
+}
+
+func TestWriteStatusJSON(t *testing.T) {
+	statuses := []MigrationStatus{
+		{Timestamp: 1, Description: "first", Registered: true},
+	}
+	var buf bytes.Buffer
+	if err := WriteStatus(&buf, statuses, "json"); err != nil {
+		t.Fatal(err.Error())
+	}
+	decoded := []MigrationStatus{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(decoded) != 1 || decoded[0].Timestamp != 1 || decoded[0].Migrated != nil {
+		t.Fatal("Unexpected json", buf.String())
+	}
+}
+
+func TestWriteStatusUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteStatus(&buf, nil, "xml"); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
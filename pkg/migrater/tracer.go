@@ -0,0 +1,65 @@
+package migrater
+
+import "context"
+
+// Tracer starts spans around commands and migrations.
+// Span of the migration is a child of the command span
+// and ctx returned by Start is passed to migration code,
+// so an OpenTelemetry tracer can be plugged in with
+// a small adapter
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// nopTracer is the default tracer,
+// which does not record anything
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttribute(key string, value interface{}) {}
+func (nopSpan) RecordError(err error)                      {}
+func (nopSpan) End()                                       {}
+
+// SetTracer sets tracer creating spans for commands
+// and migrations. Nil disables tracing
+func (m *migrater) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = nopTracer{}
+	}
+	m.tracer = tracer
+}
+
+// executeSpan executes migration inside a span
+// annotated with the migration and outcome
+func (m *migrater) executeSpan(ctx context.Context, migration Migration, direction Direction, rec MigrationRecord) error {
+	ctx, span := m.tracer.Start(ctx, "migrater.Migration")
+	span.SetAttribute("migration.timestamp", migration.GetTimestamp())
+	span.SetAttribute("migration.description", migration.GetDescription())
+	span.SetAttribute("migration.direction", direction.String())
+	err := m.execute(ctx, migration, direction, rec)
+	endSpan(span, err)
+	return err
+}
+
+// endSpan sets outcome of the span and ends it
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.SetAttribute("outcome", "failure")
+		span.RecordError(err)
+	} else {
+		span.SetAttribute("outcome", "success")
+	}
+	span.End()
+}
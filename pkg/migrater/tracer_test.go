@@ -0,0 +1,114 @@
+package migrater
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/malekim/migrater/internal/sqlitetest"
+)
+
+// memorySpan is a span kept by memoryTracer
+type memorySpan struct {
+	name   string
+	parent *memorySpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *memorySpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *memorySpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *memorySpan) End() {
+	s.ended = true
+}
+
+type spanKey struct{}
+
+// memoryTracer keeps started spans in memory
+type memoryTracer struct {
+	spans []*memorySpan
+}
+
+func (tr *memoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*memorySpan)
+	span := &memorySpan{name: name, parent: parent, attrs: map[string]interface{}{}}
+	tr.spans = append(tr.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestSQLiteTracer(t *testing.T) {
+	db := sqlitetest.Open(t)
+	defer db.Close()
+	m := NewMigrater()
+	m.SetSQLiteDatabase(db)
+	tracer := &memoryTracer{}
+	m.SetTracer(tracer)
+
+	var inMigration *memorySpan
+	calls := []string{}
+	migration := sqliteMigration(1, &calls)
+	up := migration.Up
+	migration.Up = func(ctx context.Context, tx *sql.Tx) error {
+		inMigration, _ = ctx.Value(spanKey{}).(*memorySpan)
+		return up(ctx, tx)
+	}
+	m.AddSQLMigration(migration)
+	failing := sqliteMigration(2, &calls)
+	failing.Up = func(ctx context.Context, tx *sql.Tx) error {
+		return errors.New("Testing purpose error")
+	}
+	m.AddSQLMigration(failing)
+
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if len(tracer.spans) != 3 {
+		t.Fatal("Expected", 3, "Got", len(tracer.spans))
+	}
+	run, first, second := tracer.spans[0], tracer.spans[1], tracer.spans[2]
+	if run.name != "migrater.Run" || run.parent != nil || !run.ended || run.err == nil {
+		t.Fatal("Unexpected span", run)
+	}
+	if first.name != "migrater.Migration" || first.parent != run || !first.ended {
+		t.Fatal("Unexpected span", first)
+	}
+	if first.attrs["migration.timestamp"] != uint64(1) || first.attrs["migration.description"] != "Migration 1" {
+		t.Fatal("Unexpected attributes", first.attrs)
+	}
+	if first.attrs["migration.direction"] != "up" || first.attrs["outcome"] != "success" {
+		t.Fatal("Unexpected attributes", first.attrs)
+	}
+	if inMigration != first {
+		t.Fatal("Migration should get context with its span")
+	}
+	if second.attrs["outcome"] != "failure" || second.err == nil || second.parent != run {
+		t.Fatal("Unexpected span", second)
+	}
+
+	tracer.spans = nil
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(tracer.spans) != 2 || tracer.spans[0].name != "migrater.Rollback" {
+		t.Fatal("Unexpected spans", tracer.spans)
+	}
+	if tracer.spans[1].attrs["migration.direction"] != "down" || tracer.spans[0].attrs["outcome"] != "success" {
+		t.Fatal("Unexpected spans", tracer.spans)
+	}
+}
+
+func TestSetTracerNil(t *testing.T) {
+	m := NewMigrater()
+	m.SetTracer(nil)
+	if _, ok := m.tracer.(nopTracer); !ok {
+		t.Fatal("Expected", "nopTracer", "Got", m.tracer)
+	}
+}
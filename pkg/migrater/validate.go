@@ -0,0 +1,81 @@
+package migrater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ChecksumMismatch describes applied migration
+// which code changed after it was applied
+type ChecksumMismatch struct {
+	Timestamp   uint64
+	Description string
+	Applied     string
+	Registered  string
+}
+
+// ChecksumError is returned by Validate when checksum
+// of any applied migration does not match registered one
+type ChecksumError struct {
+	Mismatches []ChecksumMismatch
+}
+
+func (e *ChecksumError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Checksum of %d applied migrations does not match registered ones:", len(e.Mismatches))
+	for _, mm := range e.Mismatches {
+		fmt.Fprintf(&b, "\n  %d (%s): applied `%s`, registered `%s`", mm.Timestamp, mm.Description, mm.Applied, mm.Registered)
+	}
+	return b.String()
+}
+
+// Validate checks that registered migrations were not
+// changed after they were applied. Run, MigrateTo and Steps
+// call it before applying anything
+func (m *migrater) Validate() error {
+	return m.ValidateContext(context.Background())
+}
+
+func (m *migrater) ValidateContext(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return m.validate(applied)
+}
+
+// validate compares checksums of registered migrations
+// with applied ones. Records without checksum are skipped,
+// they were applied before checksums were saved
+func (m *migrater) validate(applied map[uint64]MigrationRecord) error {
+	mismatches := []ChecksumMismatch{}
+	for _, migration := range m.migrations {
+		rec, ok := applied[migration.GetTimestamp()]
+		if !ok || rec.Checksum == "" {
+			continue
+		}
+		registered := checksum(migration)
+		if rec.Checksum != registered {
+			mismatches = append(mismatches, ChecksumMismatch{
+				Timestamp:   migration.GetTimestamp(),
+				Description: migration.GetDescription(),
+				Applied:     rec.Checksum,
+				Registered:  registered,
+			})
+		}
+	}
+	if len(mismatches) > 0 {
+		return &ChecksumError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// checksum returns checksum of migration or
+// empty string when migration does not have one
+func checksum(migration Migration) string {
+	if c, ok := migration.(Checksummer); ok {
+		return c.GetChecksum()
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package migrater
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{
+		records: []MigrationRecord{
+			{Timestamp: 1, Description: "1", Checksum: "a"},
+			{Timestamp: 2, Description: "2", Checksum: "a"},
+			{Timestamp: 3, Description: "3"},
+		},
+	}
+	m.SetDriver(d)
+	first := memoryMigration(1, "1")
+	first.Checksum = "a"
+	second := memoryMigration(2, "2")
+	second.Checksum = "b"
+	third := memoryMigration(3, "3")
+	third.Checksum = "c"
+	m.AddMigration(first)
+	m.AddMigration(second)
+	m.AddMigration(third)
+	m.AddMigration(memoryMigration(4, "4"))
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("There should be an error")
+	}
+	checksumErr, ok := err.(*ChecksumError)
+	if !ok {
+		t.Fatal("Expected *ChecksumError, Got", err)
+	}
+	// migration 3 was applied without checksum
+	if len(checksumErr.Mismatches) != 1 {
+		t.Fatal("Expected", 1, "Got", checksumErr.Mismatches)
+	}
+	mm := checksumErr.Mismatches[0]
+	if mm.Timestamp != 2 || mm.Applied != "a" || mm.Registered != "b" {
+		t.Fatal("Unexpected mismatch", mm)
+	}
+	if !strings.Contains(err.Error(), "2 (2): applied `a`, registered `b`") {
+		t.Fatal("Unexpected report", err.Error())
+	}
+	// Run refuses to apply migration 4
+	if err := m.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if len(d.calls) > 0 {
+		t.Fatal("Nothing should be executed, Got", d.calls)
+	}
+}
+
+func TestRunSavesChecksum(t *testing.T) {
+	m := NewMigrater()
+	d := &memoryDriver{}
+	m.SetDriver(d)
+	migration := memoryMigration(1, "1")
+	migration.Checksum = "v1"
+	m.AddMigration(migration)
+
+	if err := m.Run(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if d.records[0].Checksum != "v1" {
+		t.Fatal("Expected", "v1", "Got", d.records[0].Checksum)
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err.Error())
+	}
+}
@@ -0,0 +1,311 @@
+// Package migratercli builds cobra commands running
+// migrations, so every service gets the same CLI
+package migratercli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/malekim/migrater/pkg/migrater"
+
+	"github.com/spf13/cobra"
+)
+
+// DatabaseFunc connects migrater to the database, for example
+// with mig.SetMongoDatabase. It is called before every
+// subcommand, returned function closes the connection
+type DatabaseFunc func(ctx context.Context, mig migrater.Migrater) (func(), error)
+
+// NewCommand returns migrate command with up, down, to,
+// redo, resolve, status and generate subcommands. mig should
+// have migrations already added
+func NewCommand(mig migrater.Migrater, connect DatabaseFunc) *cobra.Command {
+	c := &command{mig: mig, connect: connect}
+
+	root := &cobra.Command{
+		Use:   "migrate",
+		Short: "Run migrations",
+		RunE:  requireSubcommand,
+	}
+
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending migrations",
+		Args:  c.upArgs,
+		RunE:  c.run(c.up),
+	}
+	up.Flags().IntVar(&c.steps, "steps", 0, "number of migrations to apply")
+	up.Flags().BoolVar(&c.dryRun, "dry-run", false, "print migrations without running them")
+	up.Flags().StringVar(&c.target, "to", "", "migrate up or down to the migration with timestamp")
+
+	down := &cobra.Command{
+		Use:   "down [timestamp...]",
+		Short: "Rollback migrations",
+		Args:  c.downArgs,
+		RunE:  c.run(c.down),
+	}
+	down.Flags().IntVar(&c.steps, "steps", 0, "number of migrations to rollback")
+	down.Flags().BoolVar(&c.lastBatch, "last-batch", false, "rollback migrations applied by the last run")
+	down.Flags().BoolVar(&c.dryRun, "dry-run", false, "print migrations without running them")
+
+	to := &cobra.Command{
+		Use:   "to timestamp",
+		Short: "Migrate up or down to the migration",
+		Args:  cobra.ExactArgs(1),
+		RunE:  c.run(c.to),
+	}
+
+	redo := &cobra.Command{
+		Use:   "redo",
+		Short: "Rollback the last migration and apply it again",
+		Args:  cobra.NoArgs,
+		RunE:  c.run(c.redo),
+	}
+
+	resolve := &cobra.Command{
+		Use:   "resolve timestamp",
+		Short: "Clear dirty state of migration failed midway",
+		Long: `Clear dirty state of migration after the database was fixed manually.
+Migration is forgotten and will be run again, unless --applied is set.`,
+		Args: cobra.ExactArgs(1),
+		RunE: c.run(c.resolve),
+	}
+	resolve.Flags().BoolVar(&c.applied, "applied", false, "keep migration as applied")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending migrations",
+		Args:  cobra.NoArgs,
+		RunE:  c.run(c.status),
+	}
+	status.Flags().StringVar(&c.format, "format", "table", "output format: table or json")
+
+	root.AddCommand(up, down, to, redo, resolve, status, NewGenerateCommand())
+	return root
+}
+
+// command keeps flags of a single command tree
+type command struct {
+	mig       migrater.Migrater
+	connect   DatabaseFunc
+	steps     int
+	lastBatch bool
+	dryRun    bool
+	target    string
+	applied   bool
+	format    string
+}
+
+// run connects to the database before calling fn
+// and cancels the context on SIGINT or SIGTERM
+func (c *command) run(fn func(ctx context.Context, cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := signalContext()
+		defer cancel()
+		closeDB, err := c.connect(ctx, c.mig)
+		if err != nil {
+			return err
+		}
+		if closeDB != nil {
+			defer closeDB()
+		}
+		return fn(ctx, cmd, args)
+	}
+}
+
+// upArgs rejects flags which cannot be combined,
+// before connecting to the database
+func (c *command) upArgs(cmd *cobra.Command, args []string) error {
+	if err := cobra.NoArgs(cmd, args); err != nil {
+		return err
+	}
+	if c.steps < 0 {
+		return fmt.Errorf("--steps must be positive")
+	}
+	if c.target != "" && (c.dryRun || c.steps > 0) {
+		return fmt.Errorf("--to cannot be used with --dry-run or --steps")
+	}
+	return nil
+}
+
+// downArgs rejects flags which cannot be combined
+// with each other or with timestamps
+func (c *command) downArgs(cmd *cobra.Command, args []string) error {
+	if c.steps < 0 {
+		return fmt.Errorf("--steps must be positive")
+	}
+	if c.steps > 0 && c.lastBatch {
+		return fmt.Errorf("--steps cannot be used with --last-batch")
+	}
+	if len(args) > 0 && (c.steps > 0 || c.lastBatch) {
+		return fmt.Errorf("timestamps cannot be used with --steps or --last-batch")
+	}
+	return nil
+}
+
+func (c *command) up(ctx context.Context, cmd *cobra.Command, args []string) error {
+	if c.target != "" {
+		return c.mig.MigrateToContext(ctx, c.target)
+	}
+	if c.dryRun {
+		if c.steps > 0 {
+			plan, err := c.mig.PlanStepsContext(ctx, c.steps)
+			return printPlan(cmd, plan, err)
+		}
+		plan, err := c.mig.PlanRunContext(ctx)
+		return printPlan(cmd, plan, err)
+	}
+	if c.steps > 0 {
+		return c.mig.StepsContext(ctx, c.steps)
+	}
+	return c.mig.RunContext(ctx)
+}
+
+func (c *command) down(ctx context.Context, cmd *cobra.Command, args []string) error {
+	if c.dryRun {
+		var plan *migrater.Plan
+		var err error
+		switch {
+		case c.lastBatch:
+			plan, err = c.mig.PlanRollbackLastBatchContext(ctx)
+		case c.steps > 0:
+			plan, err = c.mig.PlanStepsContext(ctx, -c.steps)
+		default:
+			plan, err = c.mig.PlanRollbackContext(ctx, args...)
+		}
+		return printPlan(cmd, plan, err)
+	}
+	switch {
+	case c.lastBatch:
+		return c.mig.RollbackLastBatchContext(ctx)
+	case c.steps > 0:
+		return c.mig.StepsContext(ctx, -c.steps)
+	}
+	return c.mig.RollbackContext(ctx, args...)
+}
+
+func (c *command) to(ctx context.Context, cmd *cobra.Command, args []string) error {
+	return c.mig.MigrateToContext(ctx, args[0])
+}
+
+func (c *command) redo(ctx context.Context, cmd *cobra.Command, args []string) error {
+	return c.mig.RedoContext(ctx)
+}
+
+func (c *command) resolve(ctx context.Context, cmd *cobra.Command, args []string) error {
+	return c.mig.ResolveContext(ctx, args[0], c.applied)
+}
+
+func (c *command) status(ctx context.Context, cmd *cobra.Command, args []string) error {
+	statuses, err := c.mig.StatusContext(ctx)
+	if err != nil {
+		return err
+	}
+	return migrater.WriteStatus(cmd.OutOrStdout(), statuses, c.format)
+}
+
+func printPlan(cmd *cobra.Command, plan *migrater.Plan, err error) error {
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(cmd.OutOrStdout(), plan)
+	return nil
+}
+
+// NewGenerateCommand returns generate command with
+// a subcommand for every driver and registry subcommand.
+// Flags take precedence over the project config
+func NewGenerateCommand() *cobra.Command {
+	var config, dir, pkg, name string
+	generatorConfig := func() (migrater.GeneratorConfig, error) {
+		cfg, err := migrater.LoadGeneratorConfig(config)
+		if err != nil {
+			return cfg, err
+		}
+		if dir != "" {
+			cfg.Dir = dir
+		}
+		if pkg != "" {
+			cfg.Package = pkg
+		}
+		cfg.Name = name
+		return cfg, nil
+	}
+
+	generate := &cobra.Command{
+		Use:   "generate",
+		Short: "Add migration file",
+		Long: `Add migration file to app/migrations directory.
+
+Directory and package can be set with flags or in .migrater.yaml:
+
+  dir: db/migrations
+  package: migrations`,
+		RunE: requireSubcommand,
+	}
+	flags := generate.PersistentFlags()
+	flags.StringVar(&config, "config", migrater.DefaultConfigFile, "project config file")
+	flags.StringVar(&dir, "dir", "", "migrations directory, defaults to app/migrations")
+	flags.StringVar(&pkg, "package", "", "package name, defaults to migrations")
+	flags.StringVar(&name, "name", "", "migration description, added to the file name")
+
+	generate.AddCommand(&cobra.Command{
+		Use:   "registry",
+		Short: "Rewrite registry.go listing all migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := generatorConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.Dir == "" {
+				cfg.Dir = filepath.Join("app", "migrations")
+			}
+			return migrater.WriteRegistry(cfg.Dir)
+		},
+	})
+	for _, driver := range []string{"mongo", "postgres", "sqlite", "mysql"} {
+		driver := driver
+		generate.AddCommand(&cobra.Command{
+			Use:   driver,
+			Short: fmt.Sprintf("Add %s migration file", driver),
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cfg, err := generatorConfig()
+				if err != nil {
+					return err
+				}
+				return migrater.GenerateMigrationFile(driver, cfg)
+			},
+		})
+	}
+	return generate
+}
+
+func requireSubcommand(cmd *cobra.Command, args []string) error {
+	cmd.Help()
+	return fmt.Errorf("%s requires a subcommand", cmd.Name())
+}
+
+// signalContext is cancelled on SIGINT or SIGTERM,
+// so migrater does not start the next migration
+func signalContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sig)
+		cancel()
+	}
+}
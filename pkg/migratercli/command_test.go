@@ -0,0 +1,230 @@
+package migratercli
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malekim/migrater/internal/migratertest"
+	"github.com/malekim/migrater/pkg/migrater"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// execute runs command with args against sqlite
+// database with migrations 1, 2 and 3
+func execute(t *testing.T, path string, args ...string) string {
+	mig := migrater.NewMigrater()
+	for i := uint64(1); i <= 3; i++ {
+		mig.AddSQLMigration(migratertest.SQLMigration(i))
+	}
+	cmd := NewCommand(mig, func(ctx context.Context, mig migrater.Migrater) (func(), error) {
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			return nil, err
+		}
+		mig.SetSQLiteDatabase(db)
+		return func() { db.Close() }, nil
+	})
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buf.String()
+}
+
+func databasePath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "migratercli")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "app.db")
+}
+
+func pending(t *testing.T, path string) int {
+	out := execute(t, path, "status", "--format", "table")
+	return strings.Count(out, "pending")
+}
+
+func TestCommandUpAndDown(t *testing.T) {
+	path := databasePath(t)
+
+	out := execute(t, path, "up", "--dry-run")
+	if out != "up 1 (Migration 1)\nup 2 (Migration 2)\nup 3 (Migration 3)\n" {
+		t.Fatal("Unexpected plan", out)
+	}
+	if n := pending(t, path); n != 3 {
+		t.Fatal("Expected", 3, "Got", n)
+	}
+	execute(t, path, "up", "--steps", "1")
+	if n := pending(t, path); n != 2 {
+		t.Fatal("Expected", 2, "Got", n)
+	}
+	execute(t, path, "up")
+	if n := pending(t, path); n != 0 {
+		t.Fatal("Expected", 0, "Got", n)
+	}
+	out = execute(t, path, "down", "--last-batch", "--dry-run")
+	if out != "down 3 (Migration 3)\ndown 2 (Migration 2)\n" {
+		t.Fatal("Unexpected plan", out)
+	}
+	execute(t, path, "down", "--steps", "1")
+	if n := pending(t, path); n != 1 {
+		t.Fatal("Expected", 1, "Got", n)
+	}
+	execute(t, path, "down", "1")
+	if n := pending(t, path); n != 2 {
+		t.Fatal("Expected", 2, "Got", n)
+	}
+}
+
+func TestCommandToAndRedo(t *testing.T) {
+	path := databasePath(t)
+
+	execute(t, path, "to", "2")
+	if n := pending(t, path); n != 1 {
+		t.Fatal("Expected", 1, "Got", n)
+	}
+	execute(t, path, "redo")
+	if n := pending(t, path); n != 1 {
+		t.Fatal("Expected", 1, "Got", n)
+	}
+	execute(t, path, "to", "1")
+	if n := pending(t, path); n != 2 {
+		t.Fatal("Expected", 2, "Got", n)
+	}
+}
+
+func TestCommandUpTo(t *testing.T) {
+	path := databasePath(t)
+
+	execute(t, path, "up", "--to", "2")
+	if n := pending(t, path); n != 1 {
+		t.Fatal("Expected", 1, "Got", n)
+	}
+	execute(t, path, "up", "--to", "1")
+	if n := pending(t, path); n != 2 {
+		t.Fatal("Expected", 2, "Got", n)
+	}
+}
+
+func TestCommandInvalidFlags(t *testing.T) {
+	invalid := [][]string{
+		{"up", "--to", "1", "--dry-run"},
+		{"up", "--to", "1", "--steps", "1"},
+		{"up", "--steps", "-2"},
+		{"down", "--steps", "-1"},
+		{"down", "--steps", "1", "--last-batch"},
+		{"down", "--steps", "1", "2"},
+		{"down", "--last-batch", "2"},
+	}
+	for _, args := range invalid {
+		connected := false
+		cmd := NewCommand(migrater.NewMigrater(), func(ctx context.Context, mig migrater.Migrater) (func(), error) {
+			connected = true
+			return nil, nil
+		})
+		cmd.SetOut(ioutil.Discard)
+		cmd.SetErr(ioutil.Discard)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Fatal("There should be an error for", args)
+		}
+		if connected {
+			t.Fatal("Database should not be connected for", args)
+		}
+	}
+}
+
+// dirtyDriver has single dirty migration 1
+type dirtyDriver struct {
+	dirty   bool
+	applied bool
+}
+
+func (d *dirtyDriver) Lock(ctx context.Context, wait time.Duration) error { return nil }
+func (d *dirtyDriver) Unlock(ctx context.Context) error                   { return nil }
+func (d *dirtyDriver) Applied(ctx context.Context) ([]migrater.MigrationRecord, error) {
+	return []migrater.MigrationRecord{{Timestamp: 1, Dirty: d.dirty}}, nil
+}
+func (d *dirtyDriver) Record(ctx context.Context, rec migrater.MigrationRecord) error { return nil }
+func (d *dirtyDriver) Remove(ctx context.Context, timestamp uint64) error             { return nil }
+func (d *dirtyDriver) Execute(ctx context.Context, mgtn migrater.Migration, direction migrater.Direction) error {
+	return nil
+}
+
+func (d *dirtyDriver) Resolve(ctx context.Context, timestamp uint64, applied bool) error {
+	d.dirty = false
+	d.applied = applied
+	return nil
+}
+
+func TestCommandResolve(t *testing.T) {
+	d := &dirtyDriver{dirty: true}
+	cmd := NewCommand(migrater.NewMigrater(), func(ctx context.Context, mig migrater.Migrater) (func(), error) {
+		mig.SetDriver(d)
+		return nil, nil
+	})
+	cmd.SetOut(ioutil.Discard)
+	cmd.SetErr(ioutil.Discard)
+	cmd.SetArgs([]string{"resolve", "1", "--applied"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if d.dirty || !d.applied {
+		t.Fatal("Migration should be resolved as applied")
+	}
+}
+
+func TestCommandConnectError(t *testing.T) {
+	cmd := NewCommand(migrater.NewMigrater(), func(ctx context.Context, mig migrater.Migrater) (func(), error) {
+		return nil, errors.New("Connection refused")
+	})
+	cmd.SetOut(ioutil.Discard)
+	cmd.SetErr(ioutil.Discard)
+	cmd.SetArgs([]string{"up"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestCommandRequiresSubcommand(t *testing.T) {
+	cmd := NewCommand(migrater.NewMigrater(), nil)
+	cmd.SetOut(ioutil.Discard)
+	cmd.SetErr(ioutil.Discard)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("There should be an error")
+	}
+}
+
+func TestCommandGenerate(t *testing.T) {
+	dir := filepath.Dir(databasePath(t))
+	cmd := NewCommand(migrater.NewMigrater(), nil)
+	cmd.SetArgs([]string{"generate", "sqlite", "--dir", dir, "--package", "db", "--name", "add users"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err.Error())
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*_add_users.go"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(files) != 1 {
+		t.Fatal("Expected", 1, "Got", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "registry.go")); err != nil {
+		t.Fatal(err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+// Package migraterprom records migration
+// metrics with prometheus client
+package migraterprom
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/malekim/migrater/pkg/migrater"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// namespace prefixes names of all metrics
+const namespace = "migrater"
+
+// Metrics implements migrater.Metrics
+// with prometheus collectors
+type Metrics struct {
+	applied    prometheus.Counter
+	rolledBack prometheus.Counter
+	failed     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	version    prometheus.Gauge
+	lockWait   prometheus.Histogram
+}
+
+// New creates metrics and registers them in reg,
+// use prometheus.DefaultRegisterer for the default registry
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		applied: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "migrations_applied_total",
+			Help:      "Number of applied migrations.",
+		}),
+		rolledBack: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "migrations_rolled_back_total",
+			Help:      "Number of reverted migrations.",
+		}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "migrations_failed_total",
+			Help:      "Number of failed migrations by direction.",
+		}, []string{"direction"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "migration_duration_seconds",
+			Help:      "Duration of migrations by timestamp, direction and outcome.",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300, 900},
+		}, []string{"timestamp", "direction", "outcome"}),
+		version: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "schema_version",
+			Help:      "Timestamp of the latest applied migration.",
+		}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "lock_wait_seconds",
+			Help:      "Time spent acquiring the migrations lock.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+	collectors := []prometheus.Collector{m.applied, m.rolledBack, m.failed, m.duration, m.version, m.lockWait}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ObserveMigration counts migration and records its duration
+func (m *Metrics) ObserveMigration(event migrater.MigrationEvent) {
+	direction := event.Direction.String()
+	outcome := "success"
+	switch {
+	case event.Err != nil:
+		outcome = "failure"
+		m.failed.WithLabelValues(direction).Inc()
+	case event.Direction == migrater.Down:
+		m.rolledBack.Inc()
+	default:
+		m.applied.Inc()
+	}
+	timestamp := strconv.FormatUint(event.Migration.GetTimestamp(), 10)
+	m.duration.WithLabelValues(timestamp, direction, outcome).Observe(event.Duration.Seconds())
+}
+
+// SetVersion sets current schema version
+func (m *Metrics) SetVersion(timestamp uint64) {
+	m.version.Set(float64(timestamp))
+}
+
+// ObserveLockWait records time spent acquiring the lock
+func (m *Metrics) ObserveLockWait(wait time.Duration) {
+	m.lockWait.Observe(wait.Seconds())
+}
@@ -0,0 +1,70 @@
+package migraterprom
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/malekim/migrater/internal/migratertest"
+	"github.com/malekim/migrater/internal/sqlitetest"
+	"github.com/malekim/migrater/pkg/migrater"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics, err := New(reg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mig := migrater.NewMigrater()
+	mig.SetSQLiteDatabase(sqlitetest.Open(t))
+	mig.SetMetrics(metrics)
+	mig.AddSQLMigration(migratertest.SQLMigration(1))
+	mig.AddSQLMigration(migratertest.SQLMigration(2))
+	failing := migratertest.SQLMigration(3)
+	failing.Up = func(ctx context.Context, tx *sql.Tx) error {
+		return errors.New("Testing purpose error")
+	}
+	mig.AddSQLMigration(failing)
+
+	if err := mig.Run(); err == nil {
+		t.Fatal("There should be an error")
+	}
+	if err := mig.Rollback("2"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if v := testutil.ToFloat64(metrics.applied); v != 2 {
+		t.Fatal("Expected", 2, "Got", v)
+	}
+	if v := testutil.ToFloat64(metrics.rolledBack); v != 1 {
+		t.Fatal("Expected", 1, "Got", v)
+	}
+	if v := testutil.ToFloat64(metrics.failed.WithLabelValues("up")); v != 1 {
+		t.Fatal("Expected", 1, "Got", v)
+	}
+	if v := testutil.ToFloat64(metrics.version); v != 1 {
+		t.Fatal("Expected", 1, "Got", v)
+	}
+	// up of 1, 2 and 3 and down of 2
+	if n := testutil.CollectAndCount(metrics.duration); n != 4 {
+		t.Fatal("Expected", 4, "Got", n)
+	}
+	if n := testutil.CollectAndCount(metrics.lockWait); n != 1 {
+		t.Fatal("Expected", 1, "Got", n)
+	}
+}
+
+func TestNewRegisterError(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := New(reg); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := New(reg); err == nil {
+		t.Fatal("There should be an error")
+	}
+}